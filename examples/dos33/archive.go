@@ -0,0 +1,138 @@
+package dos33
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/nufx"
+)
+
+// loadArchive reads the ShrinkIt file archive (.shk) at path into a read-only
+// directory of its records.
+//
+// Each file is named with its ProDOS file and aux types, as in "HELLO#FC0801",
+// and its resource fork, if any, has an "r" on the end. Disk images in the
+// archive are ProDOS-ordered, so they end in ".po".
+func loadArchive(path string) (*memDir, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := nufx.Read(data)
+	if err != nil {
+		return nil, err
+	}
+	modTime := archive.Modified
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	name := filepath.Base(path)
+	root := &memDir{
+		name:     strings.TrimSuffix(name, filepath.Ext(name)),
+		modTime:  modTime,
+		children: newDirEntries(),
+	}
+	for _, record := range archive.Records {
+		parts := record.Path()
+		dir := root
+		for _, part := range parts[:len(parts)-1] {
+			dir = dir.subdir(part)
+		}
+
+		name := parts[len(parts)-1]
+		if record.FileType == 0x0F { // DIR
+			dir.subdir(name)
+			continue
+		}
+		if record.Has(nufx.DiskImage) {
+			dir.add(newRecordFile(name+".po", record, nufx.DiskImage))
+			continue
+		}
+		name = fmt.Sprintf("%s#%02X%04X", name, record.FileType&0xFF, record.AuxType&0xFFFF)
+		dir.add(newRecordFile(name, record, nufx.DataFork))
+		if record.Has(nufx.ResourceFork) {
+			dir.add(newRecordFile(name+"r", record, nufx.ResourceFork))
+		}
+	}
+	return root, nil
+}
+
+// subdir returns the directory called name in dir, creating it if needed.
+func (dir *memDir) subdir(name string) *memDir {
+	child, _ := dir.children.get(name)
+	if sub, ok := child.(*memDir); ok {
+		return sub
+	}
+	sub := &memDir{name: name, modTime: dir.modTime, children: newDirEntries()}
+	dir.children.add(name, sub)
+	return sub
+}
+
+func (dir *memDir) add(file *recordFile) { dir.children.add(file.name, file) }
+
+// recordFile is a fork or disk image of a record in a ShrinkIt archive,
+// decompressed when first read.
+type recordFile struct {
+	anyFile
+	name    string
+	record  *nufx.Record
+	kind    nufx.ThreadKind
+	data    *lazyBytes // shared by every open of the file
+	content *bytes.Reader
+}
+
+func newRecordFile(name string, record *nufx.Record, kind nufx.ThreadKind) *recordFile {
+	return &recordFile{name: name, record: record, kind: kind, data: &lazyBytes{}}
+}
+
+func (f *recordFile) Open() (webdav.File, error) {
+	return &recordFile{name: f.name, record: f.record, kind: f.kind, data: f.data}, nil
+}
+func (f *recordFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Read(p)
+}
+func (f *recordFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Seek(offset, whence)
+}
+func (*recordFile) Write([]byte) (int, error) { return 0, errors.ErrUnsupported }
+func (f *recordFile) Stat() (fs.FileInfo, error) {
+	modTime := f.record.Modified
+	if modTime.IsZero() {
+		modTime = time.Unix(0, 0)
+	}
+	return &fileInfo{
+		name:    f.name,
+		size:    int64(f.record.Size(f.kind)),
+		modTime: modTime,
+
+		// The archive never changes, so the handler's ETag is good enough, and
+		// does not need every record decompressed to list a folder.
+		contentType: typeBinary,
+	}, nil
+}
+func (*recordFile) Delete() error { return errors.ErrUnsupported }
+
+func (f *recordFile) load() error {
+	if f.content == nil {
+		buf, err := f.data.get(func() ([]byte, error) { return f.record.Read(f.kind) })
+		if err != nil {
+			return err
+		}
+		f.content = bytes.NewReader(buf)
+	}
+	return nil
+}
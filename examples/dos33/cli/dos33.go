@@ -12,17 +12,33 @@ import (
 func main() {
 	addr := flag.String("addr", "127.0.0.1:33333", "HTTP address on which to listen")
 	prefix := flag.String("prefix", "/dos33", "URL path prefix")
+	overlay := flag.String("overlay", "", "directory for changes, leaving the DSKs untouched")
+	fold := flag.Bool("fold", false, "find files whatever the case or Unicode normalization of their names")
+	times := flag.Bool("times", false, "keep the time each file is changed in a .meta file beside its DSK")
+	hashes := flag.String("hashes", "", "TSV or JSON file of known disks and files by hash, to identify them")
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "dos33 is a WebDAV-based filesystem for Apple DOS 3.3 DSKs.")
 		fmt.Fprintln(os.Stderr)
-		fmt.Fprintln(os.Stderr, "usage: dos33 [-addr ADDR] [-prefix PREFIX] DSK...")
+		fmt.Fprintln(os.Stderr, "usage: dos33 [-addr ADDR] [-prefix PREFIX] [-overlay DIR] [-fold] [-times] [-hashes FILE] DSK...")
 		fmt.Fprintln(os.Stderr)
 		fmt.Fprintln(os.Stderr, "DSK is one or more files for the WebDAV server to expose.")
+		fmt.Fprintln(os.Stderr, "Both DOS-ordered (.dsk, .do) and ProDOS-ordered (.po) images are supported.")
+		fmt.Fprintln(os.Stderr, "Nibble (.nib) images are supported, too, as are WOZ (.woz) images, but read-only.")
+		fmt.Fprintln(os.Stderr, "2IMG (.2mg) images are writable unless their header marks them write protected.")
+		fmt.Fprintln(os.Stderr, "ShrinkIt disk archives (.sdk) are served read-only, and file archives (.shk)")
+		fmt.Fprintln(os.Stderr, "as read-only folders of their files.")
+		fmt.Fprintln(os.Stderr, "Every disk image in a .zip is served, read-only unless there is an -overlay.")
 		fmt.Fprintln(os.Stderr)
-		for _, name := range []string{"addr", "prefix"} {
-			f := flag.Lookup(name)
-			fmt.Fprintf(os.Stderr, "-%s %s\n", f.Name, strings.ToUpper(f.Name))
-			fmt.Fprintf(os.Stderr, "  %s (default \"%s\")\n", f.Usage, f.DefValue)
+		for _, opt := range []struct{ name, arg string }{
+			{"addr", "ADDR"}, {"prefix", "PREFIX"}, {"overlay", "DIR"}, {"fold", ""}, {"times", ""}, {"hashes", "FILE"},
+		} {
+			f := flag.Lookup(opt.name)
+			fmt.Fprintln(os.Stderr, strings.TrimSpace("-"+f.Name+" "+opt.arg))
+			if f.DefValue == "" || f.DefValue == "false" {
+				fmt.Fprintf(os.Stderr, "  %s\n", f.Usage)
+			} else {
+				fmt.Fprintf(os.Stderr, "  %s (default \"%s\")\n", f.Usage, f.DefValue)
+			}
 		}
 	}
 	flag.Parse()
@@ -35,5 +51,7 @@ func main() {
 
 	disks := flag.Args()
 
-	dos33.ListenAndServe(*addr, *prefix, disks...)
+	opts := dos33.Options{OverlayDir: *overlay, FoldNames: *fold, FileTimes: *times, HashDB: *hashes}
+
+	dos33.ListenAndServe(*addr, *prefix, opts, disks...)
 }
@@ -0,0 +1,155 @@
+package dos33
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+// controlFile is a text file whose contents are a setting of a disk, such as
+// its volume number. Writing the file changes the setting when it is closed,
+// so a PUT of a new value applies it. As webdav.Handler answers any error from
+// Close with 405, withControlFiles checks the value of a PUT first.
+type controlFile struct {
+	anyFile
+	name    string
+	modTime func() time.Time
+	get     func() (string, error)
+	check   func(string) error // why set would fail, if it would
+	set     func(string) error
+
+	content *bytes.Reader
+	written *bytes.Buffer // nil until written
+}
+
+// Open returns the file, which is empty if the setting cannot be read, as on a
+// disk without DOS. Writing it then reports why.
+func (f *controlFile) Open() (webdav.File, error) {
+	var content []byte
+	if value, err := f.get(); err == nil {
+		content = []byte(value + "\n")
+	}
+	return &controlFile{
+		name: f.name, modTime: f.modTime, get: f.get, check: f.check, set: f.set,
+		content: bytes.NewReader(content),
+	}, nil
+}
+func (f *controlFile) Read(p []byte) (int, error) { return f.content.Read(p) }
+func (f *controlFile) Seek(offset int64, whence int) (int64, error) {
+	return f.content.Seek(offset, whence)
+}
+func (f *controlFile) Write(p []byte) (int, error) {
+	if f.written == nil {
+		f.written = new(bytes.Buffer)
+	}
+	return f.written.Write(p)
+}
+func (f *controlFile) Close() error {
+	if f.written == nil {
+		return nil
+	}
+	value := strings.TrimSpace(f.written.String())
+	f.written = nil
+	return f.set(value)
+}
+func (f *controlFile) Stat() (fs.FileInfo, error) {
+	size := int64(0)
+	if f.content != nil {
+		size = f.content.Size()
+	} else if value, err := f.get(); err == nil {
+		size = int64(len(value) + 1)
+	}
+	return &fileInfo{name: f.name, size: size, modTime: f.modTime(), contentType: typeText}, nil
+}
+func (*controlFile) Delete() error { return errors.ErrUnsupported }
+
+// greetingFile is GREETING.txt, the name of the program d runs at boot.
+func greetingFile(d *dsk.Diskette) *controlFile {
+	return &controlFile{name: snGreeting(), modTime: d.ModTime, get: d.Greeting, check: d.CheckGreeting, set: d.SetGreeting}
+}
+
+// volumeFile is VOLUME.txt, the volume number of d.
+func volumeFile(d *dsk.Diskette) *controlFile {
+	return &controlFile{
+		name:    snVolume(),
+		modTime: d.ModTime,
+		get:     func() (string, error) { return strconv.Itoa(int(d.Volume())), nil },
+		check: func(value string) error {
+			volume, err := parseVolume(value)
+			if err != nil {
+				return err
+			}
+			return d.CheckVolume(volume)
+		},
+		set: func(value string) error {
+			volume, err := parseVolume(value)
+			if err != nil {
+				return err
+			}
+			return d.SetVolume(volume)
+		},
+	}
+}
+
+func parseVolume(value string) (int, error) {
+	volume, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("volume %q: not a number", value)
+	}
+	return volume, nil
+}
+
+// maxControlValue is the most of a PUT to a control file that is read.
+const maxControlValue = 1 << 10
+
+// withControlFiles wraps h so that a PUT of a value a control file cannot take
+// is refused with the reason: 403 if the disk cannot be written, 409 if it has
+// no such setting, as a disk without DOS has no greeting program, and
+// otherwise 400.
+func (dfs *dos33FS) withControlFiles(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if r.Method != http.MethodPut || !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		file, _, err := walk(&rootDir{dfs: dfs}, strings.TrimLeft(name, "/"), dfs.fold)
+		control, ok := file.(*controlFile)
+		if err != nil || !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxControlValue+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxControlValue {
+			http.Error(w, fmt.Sprintf("%s: longer than %d bytes", control.name, maxControlValue), http.StatusBadRequest)
+			return
+		}
+		if err := control.check(strings.TrimSpace(string(body))); err != nil {
+			status := http.StatusBadRequest
+			switch {
+			case errors.Is(err, fs.ErrPermission):
+				status = http.StatusForbidden
+			case errors.Is(err, dsk.ErrNoGreeting):
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.ServeHTTP(w, r)
+	})
+}
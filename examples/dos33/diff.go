@@ -0,0 +1,122 @@
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+// diffDir is /_diff/, which holds DISKA..DISKB.txt for every pair of disks:
+// how DISKB differs from DISKA, as [dsk.Diff] describes it. There are too many
+// pairs to list, so it lists none, and a diff is made only when it is asked
+// for by name.
+type diffDir struct {
+	anyDir
+	dfs *dos33FS
+
+	mu    sync.Mutex
+	files map[[2]*dsk.Diskette]*diffFile // by the pair of disks
+}
+
+func (dir *diffDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
+func (dir *diffDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: snDiff(), isDir: true, modTime: dir.dfs.created}, nil
+}
+func (*diffDir) Children() *dirEntries              { return newDirEntries() }
+func (*diffDir) Create(string) (webdav.File, error) { return nil, errors.ErrUnsupported }
+
+// find returns the diff named DISKA..DISKB.txt, for any two disks.
+func (dir *diffDir) find(name string) (fileWrapper, error) {
+	ext := len(name) - len(".txt")
+	if ext < 0 || !(name[ext:] == ".txt" || dir.dfs.fold && strings.EqualFold(name[ext:], ".txt")) {
+		return nil, os.ErrNotExist
+	}
+	pair, pairs := [2]*dsk.Diskette{}, 0
+	for i := 0; i+2 <= ext; i++ {
+		if name[i:i+2] != ".." {
+			continue
+		}
+		a, b := dir.disk(name[:i]), dir.disk(name[i+2:ext])
+		if a != nil && b != nil && a != b {
+			pair, pairs = [2]*dsk.Diskette{a, b}, pairs+1
+		}
+	}
+	switch pairs {
+	case 0:
+		return nil, os.ErrNotExist
+	case 1:
+	default:
+		return nil, fmt.Errorf("%q could be the diff of more than one pair of disks: %w", name, errAmbiguousName)
+	}
+
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	if dir.files == nil {
+		dir.files = make(map[[2]*dsk.Diskette]*diffFile)
+	}
+	f, ok := dir.files[pair]
+	if !ok {
+		f = &diffFile{name: snDiffFile(pair[0].Name(), pair[1].Name()), a: pair[0], b: pair[1]}
+		dir.files[pair] = f
+	}
+	return f, nil
+}
+
+// disk returns the disk named name, if one is.
+func (dir *diffDir) disk(name string) *dsk.Diskette {
+	_, wrapper, err := lookup(dir.dfs.root, name, dir.dfs.fold)
+	if disk, ok := wrapper.(*dskDir); err == nil && ok {
+		return disk.dsk
+	}
+	return nil
+}
+
+// diffFile is the diff of two disks, made when first read and again whenever
+// either disk changes.
+type diffFile struct {
+	anyFile
+	name string
+	a, b *dsk.Diskette
+
+	mu          sync.Mutex
+	generations [2]uint64 // of a and b when text was made
+	text        string
+}
+
+func (f *diffFile) Open() (webdav.File, error) {
+	return newMemFile(f.name, f.diff(), f.modTime()), nil
+}
+func (f *diffFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{
+		name:    f.name,
+		size:    int64(len(f.diff())),
+		modTime: f.modTime(),
+
+		contentType: typeText,
+	}, nil
+}
+func (*diffFile) Delete() error { return errors.ErrUnsupported }
+
+func (f *diffFile) diff() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if generations := [2]uint64{f.a.Generation(), f.b.Generation()}; f.text == "" || f.generations != generations {
+		f.text, f.generations = dsk.Diff(f.a, f.b), generations
+	}
+	return f.text
+}
+
+// modTime returns when the later of the two disks was modified.
+func (f *diffFile) modTime() time.Time {
+	if a, b := f.a.ModTime(), f.b.ModTime(); b.After(a) {
+		return b
+	}
+	return f.a.ModTime()
+}
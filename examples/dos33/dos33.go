@@ -5,13 +5,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/webdav"
@@ -23,7 +26,23 @@ type specialName = string
 func snReadme() specialName                 { return "README.txt" }
 func snDos() specialName                    { return "_dos" }
 func snCatalog() specialName                { return "CATALOG.txt" }
+func snCatalogJSON() specialName            { return "CATALOG.json" }
+func snCatalogCSV() specialName             { return "CATALOG.csv" }
 func snVtoc() specialName                   { return "VTOC.txt" }
+func snGreeting() specialName               { return "GREETING.txt" }
+func snVolume() specialName                 { return "VOLUME.txt" }
+func snIdentify() specialName               { return "IDENTIFY.txt" }
+func snDiff() specialName                   { return "_diff" }
+func snDiffFile(a, b string) specialName    { return fmt.Sprintf("%s..%s.txt", a, b) }
+func snSearch() specialName                 { return "_search" }
+func snIndex() specialName                  { return "INDEX.txt" }
+func snOverlay() specialName                { return "overlay" }
+func snStatus() specialName                 { return "STATUS.txt" }
+func snCommit() specialName                 { return "COMMIT" }
+func snDiscard() specialName                { return "DISCARD" }
+func snImageNib() specialName               { return "image.nib" }
+func snComment() specialName                { return "COMMENT.txt" }
+func snExport() specialName                 { return "export" }
 func snLock(filename string) specialName    { return fmt.Sprintf("%s,locked", filename) }
 func snDeleted(filename string) specialName { return fmt.Sprintf("_%s.garbage", filename) }
 func parseLockName(lockfile string) (string, bool) {
@@ -35,20 +54,39 @@ func parseLockName(lockfile string) (string, bool) {
 	}
 }
 
+// Options configures how the DOS 3.3 DSK filesystem serves its disks.
+type Options struct {
+	// OverlayDir, if set, is a directory of sidecar files holding every change
+	// made to the disks, so the disk images themselves are never modified.
+	OverlayDir string
+
+	// FoldNames, if set, lets a path name a file in any case or Unicode
+	// normalization form, as long as only one file matches.
+	FoldNames bool
+
+	// FileTimes, if set, keeps the time each file is changed in a .meta
+	// sidecar, so that files no longer all share the disk's ModTime.
+	FileTimes bool
+
+	// HashDB, if set, is a TSV or JSON file of known disks and files by their
+	// hashes, by which each disk is identified in _dos/IDENTIFY.txt.
+	HashDB string
+}
+
 // ListenAndServe starts a new WebDAV server at http://{addr}{prefix} with each
 // of the disks exposing the DOS 3.3 DSK filesystem.
-func ListenAndServe(addr, prefix string, disks ...string) error {
+func ListenAndServe(addr, prefix string, opts Options, disks ...string) error {
 	loc := fmt.Sprintf("http://%s%s", addr, prefix)
 	uri, err := url.Parse(loc)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	dosfs := newFileSystem(disks...)
+	dosfs := newFileSystemOptions(opts, disks...)
 
 	handler := webdav.Handler{
 		Prefix:     prefix,
-		LockSystem: webdav.NewMemLS(),
+		LockSystem: dosfs.locks,
 		FileSystem: dosfs,
 		Logger:     func(r *http.Request, e error) { log.Println(r.Method, r.URL.Path, e) },
 	}
@@ -58,42 +96,65 @@ func ListenAndServe(addr, prefix string, disks ...string) error {
 	for _, dsk := range dosfs.disks {
 		log.Printf("          %s/%s/\n", uri, url.PathEscape(dsk.Name()))
 	}
+	for _, archive := range dosfs.archives {
+		log.Printf("          %s/%s/\n", uri, url.PathEscape(archive.name))
+	}
+
+	return http.ListenAndServe(addr, dosfs.wrap(&handler))
+}
 
-	return http.ListenAndServe(addr, &handler)
+// wrap wraps h, which serves dfs, in the handlers for what webdav.Handler
+// leaves undone.
+func (dfs *dos33FS) wrap(h *webdav.Handler) http.Handler {
+	next := dfs.withLocks(h, withContentTypes(h))
+	return withQuotas(dfs.withControlFiles(h.Prefix, next))
 }
 
 // dos33FS is the [webdav.FileSystem] implementation for DOS 3.3 Diskettes.
 type dos33FS struct {
-	created time.Time
-	disks   []*dsk.Diskette
+	created  time.Time
+	disks    []*dsk.Diskette
+	archives []*memDir   // ShrinkIt file archives
+	root     *dirEntries // README.txt and a folder for each disk and archive
+	locks    *dosLockSystem
+	fold     bool        // see [Options.FoldNames]
+	hashes   *dsk.HashDB // see [Options.HashDB]
 	// type [webdav.FileSystem] interface
 }
 
-func (dfs *dos33FS) OpenFile(_ context.Context, name string, _ int, mode fs.FileMode) (webdav.File, error) {
+func (dfs *dos33FS) OpenFile(ctx context.Context, name string, _ int, mode fs.FileMode) (webdav.File, error) {
 	writePerms := mode.Perm()&0222 != 0
 	root := &rootDir{dfs: dfs}
 	name = strings.TrimLeft(name, "/")
-	file, basedir, err := walk(root, name)
+	file, basedir, err := walk(root, name, dfs.fold)
 	if errors.Is(err, os.ErrNotExist) && basedir != nil && writePerms {
-		return basedir.Create(path.Base(name))
+		created, cerr := basedir.Create(createName(basedir, path.Base(name), dfs.fold))
+		if errors.Is(cerr, errors.ErrUnsupported) {
+			// Nothing can be created here, so it simply does not exist.
+			return nil, err
+		}
+		return created, cerr
 	} else if err != nil {
 		return nil, err
-	} else {
-		return file.Open()
 	}
+	f, err := file.Open()
+	if d, ok := f.(*openDir); ok {
+		d.quotas = quotasRequested(ctx)
+	}
+	return f, err
 }
 
 func (dfs *dos33FS) Stat(_ context.Context, name string) (fs.FileInfo, error) {
 	root := &rootDir{dfs: dfs}
 	name = strings.TrimLeft(name, "/")
-	if file, _, err := walk(root, name); err != nil {
+	if file, _, err := walk(root, name, dfs.fold); err != nil {
 		return nil, err
 	} else {
 		return file.Stat()
 	}
 }
 
-func walk(parent fileWrapper, pathname string) (file, prev fileWrapper, err error) {
+func walk(parent fileWrapper, pathname string, fold bool) (file, prev fileWrapper, err error) {
 	if pathname == "" {
 		return parent, nil, nil
 	}
@@ -101,15 +162,20 @@ func walk(parent fileWrapper, pathname string) (file, prev fileWrapper, err erro
 	split := strings.SplitN(pathname, "/", 2)
 	name := split[0]
 
-	child, found := parent.Children()[name]
-	if !found {
-		return nil, parent, os.ErrNotExist
+	var child fileWrapper
+	if dir, ok := parent.(dynamicDir); ok {
+		child, err = dir.find(name)
+	} else {
+		_, child, err = lookup(parent.Children(), name, fold)
+	}
+	if err != nil {
+		return nil, parent, err
 	}
 	if len(split) == 1 {
 		return child, parent, nil
 	}
 	if child.IsDir() {
-		return walk(child, split[1])
+		return walk(child, split[1], fold)
 	}
 	return nil, parent, os.ErrInvalid // child is not a directory
 }
@@ -119,7 +185,7 @@ func (*dos33FS) Rename(context.Context, string, string) error     { return error
 func (dfs *dos33FS) RemoveAll(_ context.Context, name string) error {
 	root := &rootDir{dfs: dfs}
 	name = strings.TrimLeft(name, "/")
-	if file, _, err := walk(root, name); err != nil {
+	if file, _, err := walk(root, name, dfs.fold); err != nil {
 		return err
 	} else {
 		return file.Delete()
@@ -128,55 +194,216 @@ func (dfs *dos33FS) RemoveAll(_ context.Context, name string) error {
 
 // newFileSystem returns a new DOS 3.3 DSK Filesystem.
 func newFileSystem(disks ...string) *dos33FS {
-	dfs := dos33FS{created: time.Now()}
+	return newFileSystemOptions(Options{}, disks...)
+}
+
+// newFileSystemOptions returns a new DOS 3.3 DSK Filesystem configured by opts.
+func newFileSystemOptions(opts Options, disks ...string) *dos33FS {
+	dfs := dos33FS{created: time.Now(), fold: opts.FoldNames}
+	load, loadZip := dsk.LoadDiskette, dsk.LoadZip
+	if opts.OverlayDir != "" {
+		load = func(path string) (*dsk.Diskette, error) {
+			return dsk.LoadDisketteOverlay(path, opts.OverlayDir)
+		}
+		loadZip = func(path string) ([]*dsk.Diskette, []error, error) {
+			return dsk.LoadZipOverlay(path, opts.OverlayDir)
+		}
+	}
 	for _, name := range disks {
-		dsk, err := dsk.LoadDiskette(name)
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".shk":
+			archive, err := loadArchive(name)
+			if err != nil {
+				log.Fatalln("Could not load archive:", name, err)
+				continue
+			}
+			dfs.archives = append(dfs.archives, archive)
+		case ".zip":
+			disks, skipped, err := loadZip(name)
+			if err != nil {
+				log.Fatalln("Could not load zip:", name, err)
+				continue
+			}
+			for _, err := range skipped {
+				log.Println("Skipping disk in zip:", err)
+			}
+			dfs.disks = append(dfs.disks, disks...)
+		default:
+			dsk, err := load(name)
+			if err != nil {
+				log.Fatalln("Could not load diskette:", name, err)
+				continue
+			}
+			dfs.disks = append(dfs.disks, dsk)
+		}
+	}
+	if opts.FileTimes {
+		for _, dsk := range dfs.disks {
+			if err := dsk.UseFileTimes(); err != nil {
+				log.Fatalln("Could not load file times:", dsk.Name(), err)
+			}
+		}
+	}
+	if opts.HashDB != "" {
+		hashes, err := dsk.LoadHashDB(opts.HashDB)
 		if err != nil {
-			log.Fatalln("Could not load diskette:", name, err)
-			continue
+			log.Fatalln("Could not load hash database:", opts.HashDB, err)
 		}
-		dfs.disks = append(dfs.disks, dsk)
+		dfs.hashes = hashes
+	}
+	dfs.locks = newLockSystem(&dfs)
+	dfs.root = newDirEntries()
+	dfs.root.add(snReadme(), newMemFile(snReadme(), readme, dfs.created))
+	for _, dsk := range dfs.disks {
+		dsk.SetName(dfs.freeName(dsk.Name()))
+		dfs.root.add(dsk.Name(), &dskDir{dsk: dsk, locks: dfs.locks, hashes: dfs.hashes})
+	}
+	for _, archive := range dfs.archives {
+		archive.name = dfs.freeName(archive.name)
+		dfs.root.add(archive.name, archive)
+	}
+	if len(dfs.disks) > 1 {
+		dfs.root.add(snDiff(), &diffDir{dfs: &dfs})
 	}
+	dfs.root.add(snSearch(), &searchDir{dfs: &dfs})
 	return &dfs
 }
 
+// freeName returns name, or if a file in the root already has it, or would
+// once folded, the first of name~2, name~3 and so on that no file has.
+func (dfs *dos33FS) freeName(name string) string {
+	taken := func(name string) bool {
+		for _, special := range []specialName{snDiff(), snSearch()} {
+			if name == special || dfs.fold && foldKey(name) == foldKey(special) {
+				return true
+			}
+		}
+		_, _, err := lookup(dfs.root, name, dfs.fold)
+		return !errors.Is(err, os.ErrNotExist)
+	}
+	free := name
+	for n := 2; taken(free); n++ {
+		free = fmt.Sprintf("%s~%d", name, n)
+	}
+	if free != name {
+		log.Printf("Serving %s as %s, as another file has its name", name, free)
+	}
+	return free
+}
+
 // fileWrapper is the base interface for all dos33FS files.
 type fileWrapper interface {
 	Open() (webdav.File, error)
 	Stat() (fs.FileInfo, error)
 
 	IsDir() bool
-	Children() map[string]fileWrapper
+	Children() *dirEntries
 	Create(string) (webdav.File, error)
 
 	Delete() error
 }
 
-func readDir(file fileWrapper) ([]fs.FileInfo, error) {
-	if !file.IsDir() {
-		return nil, errors.ErrUnsupported
+// dynamicDir is a directory whose files are made for the names asked for,
+// rather than found among its Children.
+type dynamicDir interface {
+	fileWrapper
+	find(name string) (fileWrapper, error)
+}
+
+// dirEntries are the files in a directory, in the order they were added.
+type dirEntries struct {
+	order []string
+	files map[string]fileWrapper
+}
+
+func newDirEntries() *dirEntries {
+	return &dirEntries{files: make(map[string]fileWrapper)}
+}
+
+// add adds file as name, or replaces the file already called name in place.
+func (e *dirEntries) add(name string, file fileWrapper) {
+	if _, found := e.files[name]; !found {
+		e.order = append(e.order, name)
 	}
+	e.files[name] = file
+}
 
-	children := make([]fs.FileInfo, 0, len(file.Children()))
-	for _, child := range file.Children() {
-		if info, err := child.Stat(); err == nil {
-			children = append(children, info)
-		}
+func (e *dirEntries) get(name string) (fileWrapper, bool) {
+	if e == nil {
+		return nil, false
 	}
+	file, found := e.files[name]
+	return file, found
+}
+
+// names returns the names of the files, in order.
+func (e *dirEntries) names() []string {
+	if e == nil {
+		return nil
+	}
+	return e.order
+}
+
+// openDir is an open directory, which reads its entries a page at a time.
+type openDir struct {
+	dir     fileWrapper
+	entries *dirEntries // as they were at the first Readdir
+	offset  int         // of the next entry to read
+	quotas  bool        // whether its quota properties were asked for
+}
+
+func openDirectory(dir fileWrapper) *openDir { return &openDir{dir: dir} }
 
-	return children, nil
+func (d *openDir) Close() error                   { return nil }
+func (d *openDir) Read([]byte) (int, error)       { return -1, errors.ErrUnsupported }
+func (d *openDir) Seek(int64, int) (int64, error) { return -1, errors.ErrUnsupported }
+func (d *openDir) Write([]byte) (int, error)      { return -1, errors.ErrUnsupported }
+func (d *openDir) Stat() (fs.FileInfo, error)     { return d.dir.Stat() }
+
+// Readdir returns the next count entries, or io.EOF when there are none left.
+// With a count of zero or less, it returns all the remaining entries.
+func (d *openDir) Readdir(count int) ([]fs.FileInfo, error) {
+	if d.entries == nil {
+		d.entries = d.dir.Children()
+	}
+	names := d.entries.names()[d.offset:]
+	if count > 0 && len(names) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(names) {
+		names = names[:count]
+	}
+	d.offset += len(names)
+
+	infos := make([]fs.FileInfo, 0, len(names))
+	for _, name := range names {
+		file, _ := d.entries.get(name)
+		if info, err := file.Stat(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
 }
 
 // fileInfo is the simplest implementation of [fs.FileInfo].
 type fileInfo struct {
 	name    string
 	size    int64
+	sizeOf  func() int64 // if set, the size, worked out only once it is asked for
 	isDir   bool
 	modTime time.Time
+
+	etag        func() (string, error) // see [fileInfo.ETag]
+	contentType string                 // see [fileInfo.ContentType]
 }
 
 func (f *fileInfo) Name() string { return f.name }
-func (f *fileInfo) Size() int64  { return f.size }
+func (f *fileInfo) Size() int64 {
+	if f.sizeOf != nil {
+		return f.sizeOf()
+	}
+	return f.size
+}
 func (f *fileInfo) Mode() fs.FileMode {
 	if f.isDir {
 		return fs.ModeDir | fs.ModePerm
@@ -191,21 +418,15 @@ func (f *fileInfo) Sys() any           { return nil }
 // anyDir is a partial implementation of [fileWrapper] methods common to any directory.
 type anyDir struct{}
 
-// func (dir *anyDir) Open() (webdav.File, error)         { return dir, nil }
-// func (dir *anyDir) Readdir(int) ([]fs.FileInfo, error) { return readDir(dir) }
-func (*anyDir) Delete() error                  { return errors.ErrUnsupported }
-func (*anyDir) IsDir() bool                    { return true }
-func (*anyDir) Close() error                   { return nil }
-func (*anyDir) Read([]byte) (int, error)       { return -1, errors.ErrUnsupported }
-func (*anyDir) Seek(int64, int) (int64, error) { return -1, errors.ErrUnsupported }
-func (*anyDir) Write([]byte) (int, error)      { return -1, errors.ErrUnsupported }
+func (*anyDir) Delete() error { return errors.ErrUnsupported }
+func (*anyDir) IsDir() bool   { return true }
 
 // anyFile is a partial implementation of [fileWrapper] methods common to every file.
 type anyFile struct{}
 
 func (*anyFile) IsDir() bool                        { return false }
 func (*anyFile) Close() error                       { return nil }
-func (*anyFile) Children() map[string]fileWrapper   { return nil }
+func (*anyFile) Children() *dirEntries              { return nil }
 func (*anyFile) Readdir(int) ([]fs.FileInfo, error) { return nil, errors.ErrUnsupported }
 func (*anyFile) Create(string) (webdav.File, error) { return nil, errors.ErrUnsupported }
 
@@ -215,22 +436,14 @@ type rootDir struct {
 	dfs *dos33FS
 }
 
-func (dir *rootDir) Open() (webdav.File, error)         { return dir, nil }
-func (dir *rootDir) Readdir(int) ([]fs.FileInfo, error) { return readDir(dir) }
+func (dir *rootDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
 func (dir *rootDir) Stat() (fs.FileInfo, error) {
 	return &fileInfo{
 		modTime: dir.dfs.created,
 		isDir:   true,
 	}, nil
 }
-func (dir *rootDir) Children() map[string]fileWrapper {
-	kids := make(map[string]fileWrapper)
-	kids[snReadme()] = newMemFile(snReadme(), readme, dir.dfs.created)
-	for _, dsk := range dir.dfs.disks {
-		kids[dsk.Name()] = &dskDir{dsk: dsk}
-	}
-	return kids
-}
+func (dir *rootDir) Children() *dirEntries          { return dir.dfs.root }
 func (*rootDir) Create(string) (webdav.File, error) { return nil, errors.ErrUnsupported }
 
 // memDir is an in-memory directory.
@@ -238,11 +451,10 @@ type memDir struct {
 	anyDir
 	name     string
 	modTime  time.Time
-	children map[string]fileWrapper
+	children *dirEntries
 }
 
-func (dir *memDir) Open() (webdav.File, error)         { return dir, nil }
-func (dir *memDir) Readdir(int) ([]fs.FileInfo, error) { return readDir(dir) }
+func (dir *memDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
 func (dir *memDir) Stat() (fs.FileInfo, error) {
 	return &fileInfo{
 		name:    dir.name,
@@ -250,17 +462,23 @@ func (dir *memDir) Stat() (fs.FileInfo, error) {
 		modTime: dir.modTime,
 	}, nil
 }
-func (dir *memDir) Children() map[string]fileWrapper { return dir.children }
-func (*memDir) Create(string) (webdav.File, error)   { return nil, errors.ErrUnsupported }
+func (dir *memDir) Children() *dirEntries          { return dir.children }
+func (*memDir) Create(string) (webdav.File, error) { return nil, errors.ErrUnsupported }
 
-// dskDir
+// dskDir is the folder of a disk. Its tree is built once and kept until the
+// disk changes.
 type dskDir struct {
 	anyDir
-	dsk *dsk.Diskette
+	dsk    *dsk.Diskette
+	locks  *dosLockSystem
+	hashes *dsk.HashDB // nil without a hash database
+
+	mu         sync.Mutex
+	children   *dirEntries // nil until first needed
+	generation uint64      // of the disk when children was built
 }
 
-func (dir *dskDir) Open() (webdav.File, error)         { return dir, nil }
-func (dir *dskDir) Readdir(int) ([]fs.FileInfo, error) { return readDir(dir) }
+func (dir *dskDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
 func (dir *dskDir) Stat() (fs.FileInfo, error) {
 	return &fileInfo{
 		name:    dir.dsk.Name(),
@@ -268,27 +486,59 @@ func (dir *dskDir) Stat() (fs.FileInfo, error) {
 		modTime: dir.dsk.ModTime(),
 	}, nil
 }
-func (dir *dskDir) Children() map[string]fileWrapper {
-	kids := make(map[string]fileWrapper)
-	kids[snDos()] = &memDir{
-		name:    snDos(),
-		modTime: dir.dsk.ModTime(),
-		children: map[string]fileWrapper{
-			snCatalog(): newMemFile(snCatalog(), dsk.RunCatalog(dir.dsk), dir.dsk.ModTime()),
-			snVtoc():    newMemFile(snVtoc(), dir.dsk.VTOCFile(), dir.dsk.ModTime()),
-		},
-	}
-	for _, file := range dir.dsk.Catalog() {
-		// TODO: handle the case where the path-safe name conflicts (like inverted HELLO and HELLO)
-		name := file.Name().PathSafe()
+func (dir *dskDir) Children() *dirEntries {
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	if generation := dir.dsk.Generation(); dir.children == nil || dir.generation != generation {
+		dir.children, dir.generation = dir.build(), generation
+	}
+	return dir.children
+}
+
+// build returns the tree of the disk as it is now.
+func (dir *dskDir) build() *dirEntries {
+	kids := newDirEntries()
+	names := dir.dsk.PathNames()
+	for i, file := range dir.dsk.Catalog() {
+		name := names[i]
 		if file.IsDeleted() {
 			name = snDeleted(name)
 		}
+		kids.add(name, &dskFile{dsk: dir.dsk, file: file, name: name, locks: dir.locks})
 		if file.IsLocked() {
-			kids[snLock(name)] = &lockFile{dsk: dir.dsk, file: file}
+			kids.add(snLock(name), &lockFile{dsk: dir.dsk, file: file, name: name})
+		}
+	}
+
+	dos := &memDir{name: snDos(), modTime: dir.dsk.ModTime(), children: newDirEntries()}
+	dos.children.add(snCatalog(), newMemFile(snCatalog(), dsk.RunCatalog(dir.dsk), dir.dsk.ModTime()))
+	dos.children.add(snCatalogJSON(), newMemFile(snCatalogJSON(), dsk.CatalogJSON(dir.dsk), dir.dsk.ModTime()))
+	dos.children.add(snCatalogCSV(), newMemFile(snCatalogCSV(), dsk.CatalogCSV(dir.dsk), dir.dsk.ModTime()))
+	dos.children.add(snVtoc(), newMemFile(snVtoc(), dir.dsk.VTOCFile(), dir.dsk.ModTime()))
+	dos.children.add(snGreeting(), greetingFile(dir.dsk))
+	dos.children.add(snVolume(), volumeFile(dir.dsk))
+	if dir.hashes != nil {
+		dos.children.add(snIdentify(), newMemFile(snIdentify(), dsk.IdentifyReport(dir.hashes, dir.dsk), dir.dsk.ModTime()))
+	}
+	if comment := dir.dsk.Comment(); comment != "" {
+		dos.children.add(snComment(), newMemFile(snComment(), comment, dir.dsk.ModTime()))
+	}
+	if dir.dsk.SectorsPerTrack() <= 16 {
+		dos.children.add(snImageNib(), newImageFile(dir.dsk, snImageNib()))
+	}
+	export := &memDir{name: snExport(), modTime: dir.dsk.ModTime(), children: newDirEntries()}
+	for _, ext := range []string{".dsk", ".po", ".2mg", ".zip"} {
+		if ext == ".po" && dir.dsk.SectorsPerTrack() != 16 {
+			continue
 		}
-		kids[name] = &dskFile{dsk: dir.dsk, file: file}
+		name := dir.dsk.Name() + ext
+		export.children.add(name, newImageFile(dir.dsk, name))
 	}
+	dos.children.add(snExport(), export)
+	if dir.dsk.HasOverlay() {
+		dos.children.add(snOverlay(), &overlayDir{dsk: dir.dsk})
+	}
+	kids.add(snDos(), dos)
 
 	return kids
 }
@@ -301,7 +551,7 @@ func (dir *dskDir) Create(name string) (webdav.File, error) {
 		if err := dir.dsk.Lock(file); err != nil {
 			return nil, err
 		}
-		lck := lockFile{dsk: dir.dsk, file: file}
+		lck := lockFile{dsk: dir.dsk, file: file, name: filename}
 		return lck.Open()
 	}
 	return nil, errors.ErrUnsupported
@@ -312,10 +562,14 @@ type dskFile struct {
 	anyFile
 	dsk     *dsk.Diskette
 	file    dsk.FileEntry
-	content *bytes.Reader
+	name    string            // as in the catalog's directory, see [dsk.Diskette.PathNames]
+	locks   *dosLockSystem    // for the lockdiscovery property
+	content *io.SectionReader // reads sectors only as they are needed
 }
 
-func (f *dskFile) Open() (webdav.File, error) { return f, nil }
+func (f *dskFile) Open() (webdav.File, error) {
+	return &dskFile{dsk: f.dsk, file: f.file, name: f.name, locks: f.locks}, nil
+}
 func (f *dskFile) Read(p []byte) (int, error) {
 	if err := f.load(); err != nil {
 		return 0, err
@@ -330,14 +584,13 @@ func (f *dskFile) Seek(offset int64, whence int) (int64, error) {
 }
 func (*dskFile) Write([]byte) (int, error) { return 0, errors.ErrUnsupported }
 func (f *dskFile) Stat() (fs.FileInfo, error) {
-	name := f.file.Name().PathSafe()
-	if f.file.IsDeleted() {
-		name = snDeleted(name)
-	}
 	return &fileInfo{
-		name:    name,
+		name:    f.name,
 		size:    int64(f.file.SectorsUsed() * f.dsk.SectorSize()),
-		modTime: f.dsk.ModTime(),
+		modTime: f.dsk.FileModTime(f.file),
+
+		etag:        f.etag,
+		contentType: typeBinary,
 	}, nil
 }
 func (f *dskFile) Delete() error {
@@ -346,11 +599,11 @@ func (f *dskFile) Delete() error {
 
 func (f *dskFile) load() error {
 	if f.content == nil {
-		buf, err := f.dsk.ReadAll(f.file)
+		r, err := f.dsk.Open(f.file)
 		if err != nil {
 			return err
 		}
-		f.content = bytes.NewReader(buf)
+		f.content = io.NewSectionReader(r, 0, r.Size())
 	}
 	return nil
 }
@@ -359,36 +612,49 @@ type lockFile struct {
 	anyFile
 	dsk  *dsk.Diskette
 	file dsk.FileEntry
+	name string // of the locked file
 }
 
 func (lck *lockFile) Open() (webdav.File, error) {
-	return newMemFile(snLock(lck.file.Name().PathSafe()), "", lck.dsk.ModTime()), nil
+	return newMemFile(snLock(lck.name), "", lck.dsk.ModTime()), nil
 }
 func (lck *lockFile) Delete() error {
 	return lck.dsk.Unlock(lck.file)
 }
 func (lck *lockFile) Stat() (fs.FileInfo, error) {
-	name := lck.file.Name().PathSafe()
-	if lck.file.IsDeleted() {
-		name = snDeleted(name)
-	}
-	name = snLock(name)
 	return &fileInfo{
-		name:    name,
-		modTime: lck.dsk.ModTime(),
+		name:    snLock(lck.name),
+		modTime: lck.dsk.FileModTime(lck.file),
 	}, nil
 }
 
+// lazyBytes are the contents of a file, made when first needed and then shared
+// by every request for the file.
+type lazyBytes struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// get returns the contents, calling build only the first time.
+func (l *lazyBytes) get(build func() ([]byte, error)) ([]byte, error) {
+	l.once.Do(func() { l.data, l.err = build() })
+	return l.data, l.err
+}
+
 // memFile is an in-memory file.
 type memFile struct {
 	anyFile
 	name    string
 	modTime time.Time
+	data    []byte
 	content *bytes.Reader
 }
 
-func (file *memFile) Open() (webdav.File, error) { return file, nil }
-func (f *memFile) Read(p []byte) (int, error)    { return f.content.Read(p) }
+func (file *memFile) Open() (webdav.File, error) {
+	return &memFile{name: file.name, modTime: file.modTime, data: file.data, content: bytes.NewReader(file.data)}, nil
+}
+func (f *memFile) Read(p []byte) (int, error) { return f.content.Read(p) }
 func (f *memFile) Seek(offset int64, whence int) (int64, error) {
 	return f.content.Seek(offset, whence)
 }
@@ -397,6 +663,9 @@ func (f *memFile) Stat() (fs.FileInfo, error) {
 		name:    f.name,
 		size:    f.content.Size(),
 		modTime: f.modTime,
+
+		etag:        func() (string, error) { return hashETag(f.data), nil },
+		contentType: textType(f.name),
 	}, nil
 }
 func (*memFile) Write(p []byte) (int, error) { return 0, errors.ErrUnsupported }
@@ -404,10 +673,12 @@ func (*memFile) Write(p []byte) (int, error) { return 0, errors.ErrUnsupported }
 func (*memFile) Delete() error { return errors.ErrUnsupported }
 
 func newMemFile(name, content string, modTime time.Time) *memFile {
+	data := []byte(content)
 	return &memFile{
 		name:    name,
 		modTime: modTime,
-		content: bytes.NewReader([]byte(content)),
+		data:    data,
+		content: bytes.NewReader(data),
 	}
 }
 
@@ -423,17 +694,106 @@ state of the file.
 You can delete the lock to unlock a file.
 You can create a lock to lock a file.
 
+A WebDAV LOCK on a file locks it on the disk, too, until it is unlocked or
+the lock times out. Files locked on the disk show their lock in the
+lockdiscovery property.
+
+**Properties**
+
+WebDAV clients can see each file's catalog entry as properties: its type,
+whether it is locked, the sectors it uses, where its T/S list is and, for a
+BINARY file, its load address. PROPPATCH can change the type and the lock.
+Each disk's folder also reports how much room is left on the disk.
+
 **Garbage Files**
 
 Files that have been deleted can be viewed as well.
 They start with an underscore and end with ".garbage".
 
+**File Names**
+
+Normal names, like HELLO, appear as they are. Other characters are spelled so
+that no two files share a name:
+
+  inverse characters   followed by a combining square, like H⃞E⃞L⃞L⃞O⃞
+  flashing characters  followed by a combining diamond, like H⃟I⃟
+  control characters   as control pictures, like ␇ for CTRL-G
+  / , _ ~              as ∕ ‚ ＿ ∼, so no name looks like a path, a lock or
+                       garbage
+  . and ..             as ․ and ․․
+
+When two files in the catalog have the very same name, the second is shown as
+NAME~2, the third as NAME~3 and so on.
+
+When the server is started with -fold, a file can be named in any case or
+Unicode normalization form, so hello finds HELLO, unless that would match
+more than one file.
+
 **_dos/**
 
 The _dos directory contains special files and folders.
 
   CATALOG.txt  a close approximation of running CATLOG from DOS.
+  CATALOG.json every catalog entry, deleted ones included, for scripts.
+  CATALOG.csv  the same, as CSV.
   VTOC.txt     Volume Table of Contents information that might be helpful.
+  GREETING.txt the program the disk runs when it boots. Write a new name to it
+               to boot another program.
+  VOLUME.txt   the disk's volume number. Write a number from 1 to 254 to it
+               to change it.
+  IDENTIFY.txt only when serving with -hashes: what the disk and each of its
+               files are, found by their hashes, and the hashes themselves.
+  image.nib    the whole disk as a .nib nibble image, for emulators.
+  COMMENT.txt  the comment stored in a .2mg image, if it has one.
+  export/      the whole disk as a .dsk, .po or .2mg image, or as a .zip of
+               its raw files with readable versions of BASIC and TEXT files.
+  overlay/     only when serving with an overlay (see below).
+
+**_diff/**
+
+When more than one disk is served, _diff/DISKA..DISKB.txt shows, for any two
+disks, the files added, removed, retyped, locked or unlocked on DISKB, how the
+contents of the files on both differ, and which sectors differ. _diff/ itself
+lists nothing, as there is a diff for every pair of disks.
+
+**_search/**
+
+_search/QUERY/ finds QUERY on every disk: in the names of files, in the
+listings of BASIC and TEXT files and in the contents of any other. A QUERY
+starting with $ is hex, such as $A9C1, and matches the exact bytes of any file.
+It holds a folder for each disk with the files that match, and INDEX.txt, which
+lists the disk, file, where and offset of every match. A disk whose catalog is
+damaged is searched up to the damage, and DISK.error.txt says where it is.
+
+**Zip Archives**
+
+Each disk image in a .zip file is served as its own folder, read straight from
+the archive. The zip file is never written, so changes are refused unless the
+server is started with -overlay DIR (see below), which keeps them in DIR.
+Images that are not DOS disks are skipped. Images with the same name in
+different folders are named after their folders too, such as disks!ONE.
+
+A disk or archive whose name is already taken, by another disk or by one of
+the folders here, is served as NAME~2, NAME~3 and so on.
+
+**ShrinkIt Archives**
+
+A ShrinkIt disk archive (.sdk) is served like any other disk, but read-only.
+
+A ShrinkIt file archive (.shk) is a read-only folder of the files in it. Each
+name ends with "#" and the file's ProDOS file type and aux type in hex, like
+HELLO#FC0801. A resource fork has the same name with an "r" on the end.
+
+**Overlays**
+
+When the server is started with -overlay DIR, the disk images are never
+modified. Instead, changed sectors are kept in DIR and the folders show the
+disk with those changes applied.
+
+  _dos/overlay/STATUS.txt  lists the sectors that differ from the image.
+
+You can create _dos/overlay/COMMIT to write the changes into the disk image.
+You can create _dos/overlay/DISCARD to throw the changes away.
 
 In the future, there will be special "text" folders, for view BASIC and TEXT
 files as regular text. Conversion will happen automatically on load and save!
@@ -1,15 +1,53 @@
 package dos33
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
 )
 
+// testFiles are the files on the DISK.DSK used by tests.
+var testFiles = []dsktest.File{
+	{Name: "HELLO", Type: dsktest.IntegerBasic, Data: []byte{0x05, 0x00, 0x01, 0x00, 0x01}},
+	{Name: "PROG", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, []byte{0xA9, 0xC1, 0x60})},
+}
+
+// TestMain runs the tests from a directory holding a synthesized DISK.DSK.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "dos33")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	if err := os.WriteFile(filepath.Join(dir, "DISK.DSK"), image, 0o644); err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		log.Fatalln(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestListRoot(t *testing.T) {
 	fs := newFileSystem("DISK.DSK")
 
@@ -25,7 +63,7 @@ func TestListRoot(t *testing.T) {
 
 	actual := transform(files, name)
 	slices.Sort(actual)
-	expected := []string{"DISK", "README.txt"}
+	expected := []string{"DISK", "README.txt", "_search"}
 	if !slices.Equal(expected, actual) {
 		t.Fatal(expected, "!=", actual)
 	}
@@ -52,6 +90,65 @@ func TestListDisk(t *testing.T) {
 	}
 }
 
+func TestListDiskInCatalogOrder(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK",
+		dsktest.File{Name: "ZEBRA", Type: dsktest.Text, Data: []byte{0}},
+		dsktest.File{Name: "APPLE", Type: dsktest.Text, Locked: true, Data: []byte{0}},
+		dsktest.File{Name: "MANGO", Type: dsktest.Text, Deleted: true, Data: []byte{0}},
+		dsktest.File{Name: "BANANA", Type: dsktest.Text, Data: []byte{0}},
+	)
+	fs := newFileSystem(path)
+
+	for i := 0; i < 5; i++ {
+		file, err := fs.OpenFile(context.Background(), "/DISK", 0, os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := file.Readdir(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := transform(files, name)
+		expected := []string{"ZEBRA", "APPLE", "APPLE,locked", "_MANGO.garbage", "BANANA", "_dos"}
+		if !slices.Equal(expected, actual) {
+			t.Fatal(expected, "!=", actual)
+		}
+	}
+}
+
+func TestReaddirPaging(t *testing.T) {
+	fs := newFileSystem("DISK.DSK")
+
+	file, err := fs.OpenFile(context.Background(), "/DISK", 0, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for {
+		files, err := file.Readdir(2)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 || len(files) > 2 {
+			t.Fatal("Expected one or two entries, got", len(files))
+		}
+		actual = append(actual, transform(files, name)...)
+	}
+	if expected := []string{"HELLO", "PROG", "_dos"}; !slices.Equal(expected, actual) {
+		t.Fatal(expected, "!=", actual)
+	}
+	if files, err := file.Readdir(0); err != nil || len(files) != 0 {
+		t.Fatal("Expected nothing more to read, got", files, err)
+	}
+
+	other, _ := fs.OpenFile(context.Background(), "/DISK", 0, os.ModePerm)
+	if files, err := other.Readdir(1); err != nil || len(files) != 1 || files[0].Name() != "HELLO" {
+		t.Fatal("Expected each open directory to be read from the start, got", files, err)
+	}
+}
+
 func TestBadDiskName_ThrowsMissing(t *testing.T) {
 	fs := newFileSystem()
 
@@ -94,6 +191,809 @@ func TestReadmeIsNotEmpty(t *testing.T) {
 	}
 }
 
+func TestOverlayLeavesImageUntouched(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	original, _ := os.ReadFile(path)
+	fs := newFileSystemOptions(Options{OverlayDir: t.TempDir()}, path)
+
+	if _, err := fs.OpenFile(context.Background(), "/DISK/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	if current, _ := os.ReadFile(path); !bytes.Equal(original, current) {
+		t.Fatal("Expected disk image to be untouched")
+	}
+	if _, err := fs.Stat(context.Background(), "/DISK/HELLO,locked"); err != nil {
+		t.Fatal("Expected lock to be served from the overlay:", err)
+	}
+	if status := readString(t, fs, "/DISK/_dos/overlay/STATUS.txt"); !strings.Contains(status, "1 sector(s)") {
+		t.Fatal("Expected one changed sector in status:", status)
+	}
+}
+
+func TestOverlayIsReappliedOnLoad(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	opts := Options{OverlayDir: t.TempDir()}
+
+	fs := newFileSystemOptions(opts, path)
+	if err := fs.RemoveAll(context.Background(), "/DISK/PROG"); err != nil {
+		t.Fatal(err)
+	}
+
+	fs = newFileSystemOptions(opts, path)
+	if _, err := fs.Stat(context.Background(), "/DISK/_PROG.garbage"); err != nil {
+		t.Fatal("Expected deletion to survive reload:", err)
+	}
+}
+
+func TestOverlayDiscardAndCommit(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	original, _ := os.ReadFile(path)
+	fs := newFileSystemOptions(Options{OverlayDir: t.TempDir()}, path)
+	ctx := context.Background()
+
+	if _, err := fs.OpenFile(ctx, "/DISK/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenFile(ctx, "/DISK/_dos/overlay/DISCARD", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(ctx, "/DISK/HELLO,locked"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("Expected lock to be discarded")
+	}
+
+	if _, err := fs.OpenFile(ctx, "/DISK/PROG,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenFile(ctx, "/DISK/_dos/overlay/COMMIT", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := os.ReadFile(path); bytes.Equal(original, current) {
+		t.Fatal("Expected commit to write the disk image")
+	}
+	if status := readString(t, fs, "/DISK/_dos/overlay/STATUS.txt"); !strings.Contains(status, "No changes") {
+		t.Fatal("Expected no changes after commit:", status)
+	}
+}
+
+func TestDownloadNibbleImage(t *testing.T) {
+	fs := newFileSystem("DISK.DSK")
+
+	info, err := fs.Stat(context.Background(), "/DISK/_dos/image.nib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 35*6656 {
+		t.Fatal("Expected a 35-track NIB, got", info.Size(), "bytes")
+	}
+}
+
+func TestTwoIMGComment(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	path := filepath.Join(t.TempDir(), "DISK.2mg")
+	if err := os.WriteFile(path, dsktest.TwoIMG(0, image, false, "Side A"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newFileSystem(path)
+
+	if comment := readString(t, fs, "/DISK/_dos/COMMENT.txt"); comment != "Side A" {
+		t.Fatalf("Expected the 2MG comment, got %q", comment)
+	}
+	if _, err := fs.Stat(context.Background(), "/DISK/HELLO"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShrinkItArchive(t *testing.T) {
+	shk := dsktest.ShrinkIt(dsktest.LZW2,
+		dsktest.Record{Name: "HELLO", FileType: 0xFC, AuxType: 0x0801, Data: []byte{0x01, 0x00}},
+		dsktest.Record{Name: "DOCS/README", FileType: 0x04, Data: []byte("HI\r"), Resource: []byte("RSRC")},
+	)
+	path := filepath.Join(t.TempDir(), "FILES.shk")
+	if err := os.WriteFile(path, shk, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newFileSystem(path)
+
+	if text := readString(t, fs, "/FILES/DOCS/README#040000"); text != "HI\r" {
+		t.Fatalf("Expected the data fork, got %q", text)
+	}
+	if text := readString(t, fs, "/FILES/DOCS/README#040000r"); text != "RSRC" {
+		t.Fatalf("Expected the resource fork, got %q", text)
+	}
+	if info, err := fs.Stat(context.Background(), "/FILES/HELLO#FC0801"); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 2 {
+		t.Fatal("Expected a 2-byte file, got", info.Size())
+	}
+	if err := fs.RemoveAll(context.Background(), "/FILES/HELLO#FC0801"); err == nil {
+		t.Fatal("Expected archives to be read-only")
+	}
+}
+
+func TestDuplicateDiskNames(t *testing.T) {
+	one := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	two := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	search := dsktest.Write(t, t.TempDir(), "_search.dsk", testFiles...)
+	fs := newFileSystem(one, two, search)
+
+	for _, name := range []string{"/DISK/HELLO", "/DISK~2/HELLO", "/_search~2/HELLO"} {
+		if _, err := fs.Stat(context.Background(), name); err != nil {
+			t.Error("Expected every disk to be served:", err)
+		}
+	}
+	if _, err := fs.Stat(context.Background(), "/_search/HELLO/INDEX.txt"); err != nil {
+		t.Error("Expected _search to stay the search folder:", err)
+	}
+	if diff := readString(t, fs, "/_diff/DISK..DISK~2.txt"); !strings.Contains(diff, "+++ DISK~2") {
+		t.Error("Expected the renamed disk in its diff:", diff)
+	}
+}
+
+func TestZipOfDisks(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"ONE.dsk", "TWO.dsk"} {
+		w, _ := zw.Create(name)
+		w.Write(dsktest.Image(dsktest.Standard, 254, testFiles...))
+	}
+	zw.Close()
+	path := filepath.Join(t.TempDir(), "DISKS.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newFileSystem(path)
+	for _, name := range []string{"/ONE/HELLO", "/TWO/HELLO"} {
+		if _, err := fs.Stat(context.Background(), name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := fs.OpenFile(context.Background(), "/ONE/HELLO,locked", 0, os.ModePerm)
+	if !errors.Is(err, os.ErrPermission) || !strings.Contains(err.Error(), "DISKS.zip") {
+		t.Fatal("Expected an error explaining the zip is never written, got", err)
+	}
+
+	// The disk cannot be written, so a lock is kept only in memory.
+	serve := davServer(fs)
+	lockInfo := `<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:">` +
+		`<D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`
+	if rec := serve("LOCK", "/ONE/HELLO", nil, lockInfo); rec.Code != http.StatusOK {
+		t.Fatal("Expected 200 OK, got", rec.Code, rec.Body)
+	}
+	if rec := serve("LOCK", "/ONE/HELLO", nil, lockInfo); rec.Code != webdav.StatusLocked {
+		t.Fatal("Expected a second lock to be refused, got", rec.Code)
+	}
+	if rec := serve("PUT", "/ONE/_dos/VOLUME.txt", nil, "100"); rec.Code != http.StatusForbidden {
+		t.Fatal("Expected the volume of a disk in a zip to be refused with 403 Forbidden, got", rec.Code, rec.Body)
+	}
+
+	fs = newFileSystemOptions(Options{OverlayDir: t.TempDir()}, path)
+	if _, err := fs.OpenFile(context.Background(), "/ONE/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	fs := newFileSystem("DISK.DSK")
+
+	for _, name := range []string{"DISK.dsk", "DISK.po"} {
+		info, err := fs.Stat(context.Background(), "/DISK/_dos/export/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != 143360 {
+			t.Fatalf("Expected a 143360 byte %s, got %d", name, info.Size())
+		}
+	}
+	if image := readString(t, fs, "/DISK/_dos/export/DISK.2mg"); !strings.HasPrefix(image, "2IMG") {
+		t.Fatal("Expected a 2IMG file")
+	}
+
+	archive := readString(t, fs, "/DISK/_dos/export/DISK.zip")
+	zr, err := zip.NewReader(strings.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if want := []string{"HELLO#01", "HELLO#01.txt", "PROG#040300"}; !slices.Equal(names, want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+	prog, _ := zr.Open("PROG#040300")
+	if data, _ := io.ReadAll(prog); !bytes.Equal(data, []byte{0x00, 0x03, 0x03, 0x00, 0xA9, 0xC1, 0x60}) {
+		t.Fatalf("Expected PROG with its header, got % X", data)
+	}
+}
+
+func TestExportDamagedDisk(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	// Point PROG's T/S list, in the second catalog entry, past the last track.
+	image[(17*16+15)*256+0x0B+0x23] = 0x50
+	path := filepath.Join(t.TempDir(), "DISK.DSK")
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newFileSystem(path)
+
+	dir, err := fs.OpenFile(context.Background(), "/DISK/_dos/export", 0, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := dir.Readdir(0)
+	if err != nil || !slices.Contains(transform(files, name), "DISK.zip") {
+		t.Fatal("Expected DISK.zip to be listed, got", files, err)
+	}
+
+	archive := readString(t, fs, "/DISK/_dos/export/DISK.zip")
+	zr, err := zip.NewReader(strings.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range zr.File {
+		names = append(names, file.Name)
+	}
+	if want := []string{"HELLO#01", "HELLO#01.txt", "PROG.error.txt"}; !slices.Equal(names, want) {
+		t.Fatalf("Expected %v, got %v", want, names)
+	}
+}
+
+func TestCatalogJSON(t *testing.T) {
+	fs := newFileSystem("DISK.DSK")
+
+	var records []struct {
+		Name    string `json:"name"`
+		Address int    `json:"address"`
+	}
+	if err := json.Unmarshal([]byte(readString(t, fs, "/DISK/_dos/CATALOG.json")), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Name != "PROG" || records[1].Address != 0x0300 {
+		t.Fatalf("Unexpected catalog: %+v", records)
+	}
+	if csv := readString(t, fs, "/DISK/_dos/CATALOG.csv"); !strings.HasPrefix(csv, "rawName,name,") {
+		t.Fatal("Expected a CSV header, got", csv)
+	}
+}
+
+func TestInverseAndNormalNamesAreDistinct(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK",
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte{0xC1, 0}},
+		dsktest.File{RawName: []byte{0x08, 0x05, 0x0C, 0x0C, 0x0F}, Type: dsktest.Text, Data: []byte{0xC2, 0}},
+	)
+	fs := newFileSystem(path)
+
+	file, err := fs.OpenFile(context.Background(), "/DISK", 0, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := file.Readdir(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual := transform(files, name)
+	slices.Sort(actual)
+	if expected := []string{"HELLO", "H⃞E⃞L⃞L⃞O⃞", "_dos"}; !slices.Equal(expected, actual) {
+		t.Fatal(expected, "!=", actual)
+	}
+
+	if text := readString(t, fs, "/DISK/H⃞E⃞L⃞L⃞O⃞"); !strings.HasPrefix(text, "\xC2") {
+		t.Fatalf("Expected the inverse HELLO, got %q", text)
+	}
+	if _, err := fs.OpenFile(context.Background(), "/DISK/H⃞E⃞L⃞L⃞O⃞,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(context.Background(), "/DISK/H⃞E⃞L⃞L⃞O⃞,locked"); err != nil {
+		t.Fatal("Expected the inverse HELLO to be locked:", err)
+	}
+	if _, err := fs.Stat(context.Background(), "/DISK/HELLO,locked"); err == nil {
+		t.Fatal("Expected the normal HELLO to be left unlocked")
+	}
+}
+
+func TestFoldNames(t *testing.T) {
+	dir := t.TempDir()
+	path := dsktest.Write(t, dir, "Disquette \u00E9.dsk", testFiles...)
+	fs := newFileSystemOptions(Options{FoldNames: true}, path)
+
+	if _, err := fs.Stat(context.Background(), "/disquette e\u0301/hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenFile(context.Background(), "/DISQUETTE \u00C9/Prog,LOCKED", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.Stat(context.Background(), "/Disquette \u00E9/PROG,locked")
+	if err != nil {
+		t.Fatal("Expected PROG to be locked:", err)
+	}
+	if info.Name() != "PROG,locked" {
+		t.Fatal("Expected the canonical name, got", info.Name())
+	}
+
+	// Beyond Latin-1 and Latin Extended, and with marks in another order.
+	if foldKey("Vi\u1EC7t \u1F04") != foldKey("vie\u0302\u0323t \u03B1\u0313\u0301") {
+		t.Error("Expected NFC and NFD names to fold alike")
+	}
+
+	if _, err := newFileSystem(path).Stat(context.Background(), "/Disquette \u00E9/hello"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("Expected names to be exact without FoldNames, got", err)
+	}
+
+	path = dsktest.Write(t, dir, "TWO.dsk",
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte{0}},
+		dsktest.File{RawName: []byte("\xE8\xE5\xEC\xEC\xEF"), Type: dsktest.Text, Data: []byte{0}},
+	)
+	fs = newFileSystemOptions(Options{FoldNames: true}, path)
+	if _, err := fs.Stat(context.Background(), "/TWO/hello"); err != nil {
+		t.Fatal("Expected an exact match to win:", err)
+	}
+	if _, err := fs.Stat(context.Background(), "/TWO/Hello"); !errors.Is(err, errAmbiguousName) {
+		t.Fatal("Expected HELLO and hello to be ambiguous, got", err)
+	}
+}
+
+func TestTreeIsRebuiltWhenTheDiskChanges(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	fs := newFileSystem(path)
+
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, " I 002 HELLO") {
+		t.Fatal("Expected HELLO to be unlocked:", catalog)
+	}
+	if _, err := fs.OpenFile(context.Background(), "/DISK/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "*I 002 HELLO") {
+		t.Fatal("Expected HELLO to be locked:", catalog)
+	}
+	if err := fs.RemoveAll(context.Background(), "/DISK/PROG"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(context.Background(), "/DISK/_PROG.garbage"); err != nil {
+		t.Fatal("Expected PROG to be deleted:", err)
+	}
+}
+
+func TestOpenFilesReadIndependently(t *testing.T) {
+	fs := newFileSystem("DISK.DSK")
+
+	first, _ := fs.OpenFile(context.Background(), "/DISK/_dos/VTOC.txt", 0, os.ModePerm)
+	second, _ := fs.OpenFile(context.Background(), "/DISK/_dos/VTOC.txt", 0, os.ModePerm)
+	io.CopyN(io.Discard, first, 10)
+	one, _ := io.ReadAll(first)
+	two, _ := io.ReadAll(second)
+	if len(two) != len(one)+10 {
+		t.Fatalf("Expected each open file to have its own offset, read %d then %d bytes", len(one), len(two))
+	}
+}
+
+func TestRangeRequest(t *testing.T) {
+	text := make([]byte, 3*256)
+	for i := range text {
+		text[i] = byte(i)
+	}
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", dsktest.File{Name: "TEXT", Type: dsktest.Text, Data: text})
+	handler := &webdav.Handler{FileSystem: newFileSystem(path), LockSystem: webdav.NewMemLS()}
+
+	req := httptest.NewRequest("GET", "/DISK/TEXT", nil)
+	req.Header.Set("Range", "bytes=300-309")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent {
+		t.Fatal("Expected 206 Partial Content, got", rec.Code)
+	}
+	if body := rec.Body.Bytes(); !bytes.Equal(body, text[300:310]) {
+		t.Fatalf("Expected % X, got % X", text[300:310], body)
+	}
+}
+
+func TestWebDAVLockSetsDOSLock(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK",
+		append(testFiles, dsktest.File{Name: "KEEP", Type: dsktest.Text, Locked: true, Data: []byte{0}})...)
+	fs := newFileSystem(path)
+	serve := davServer(fs)
+	const lockInfo = `<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:">` +
+		`<D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype>` +
+		`<D:owner>alice</D:owner></D:lockinfo>`
+
+	rec := serve("LOCK", "/DISK/HELLO", map[string]string{"Timeout": "Second-600"}, lockInfo)
+	if rec.Code != http.StatusOK {
+		t.Fatal("Expected 200 OK, got", rec.Code, rec.Body)
+	}
+	token := rec.Header().Get("Lock-Token")
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "*I 002 HELLO") {
+		t.Fatal("Expected HELLO to be locked on the disk:", catalog)
+	}
+
+	propfind := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop><D:lockdiscovery/></D:prop></D:propfind>`
+	rec = serve("PROPFIND", "/DISK/HELLO", map[string]string{"Depth": "0"}, propfind)
+	if body := rec.Body.String(); !strings.Contains(body, strings.Trim(token, "<>")) || !strings.Contains(body, "alice") {
+		t.Fatal("Expected the lock in lockdiscovery:", body)
+	}
+	if rec := serve("LOCK", "/DISK/HELLO", nil, lockInfo); rec.Code != webdav.StatusLocked {
+		t.Fatal("Expected a second lock to be refused, got", rec.Code)
+	}
+
+	if rec := serve("UNLOCK", "/DISK/HELLO", map[string]string{"Lock-Token": token}, ""); rec.Code != http.StatusNoContent {
+		t.Fatal("Expected 204 No Content, got", rec.Code, rec.Body)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, " I 002 HELLO") {
+		t.Fatal("Expected HELLO to be unlocked on the disk:", catalog)
+	}
+
+	// A lock is a client's because it came from LOCK, however it looks.
+	bare := strings.Replace(lockInfo, "<D:owner>alice</D:owner>", "", 1)
+	rec = serve("LOCK", "/DISK/HELLO", map[string]string{"Depth": "0", "Timeout": "Infinite"}, bare)
+	if rec.Code != http.StatusOK {
+		t.Fatal("Expected 200 OK, got", rec.Code, rec.Body)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "*I 002 HELLO") {
+		t.Fatal("Expected a lock without owner or timeout to lock HELLO on the disk:", catalog)
+	}
+	serve("UNLOCK", "/DISK/HELLO", map[string]string{"Lock-Token": rec.Header().Get("Lock-Token")}, "")
+
+	rec = serve("PROPFIND", "/DISK/KEEP", map[string]string{"Depth": "0"}, propfind)
+	if body := rec.Body.String(); !strings.Contains(body, "activelock") {
+		t.Fatal("Expected a file locked on the disk to show a lock:", body)
+	}
+	if rec := serve("LOCK", "/DISK/KEEP", nil, lockInfo); rec.Code != webdav.StatusLocked {
+		t.Fatal("Expected a file locked on the disk to refuse a lock, got", rec.Code)
+	}
+
+	if rec := serve("DELETE", "/DISK/PROG", nil, ""); rec.Code != http.StatusNoContent {
+		t.Fatal("Expected PROG to be deleted, got", rec.Code, rec.Body)
+	}
+}
+
+func TestDOSProperties(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	fs := newFileSystem(path)
+	serve := davServer(fs)
+
+	propfind := `<?xml version="1.0"?><D:propfind xmlns:D="DAV:" xmlns:dos="` + dosNamespace + `">` +
+		`<D:prop><dos:type/><dos:locked/><dos:sectors/><dos:address/></D:prop></D:propfind>`
+	rec := serve("PROPFIND", "/DISK/PROG", map[string]string{"Depth": "0"}, propfind)
+	for _, want := range []string{">B</type>", ">false</locked>", ">2</sectors>", ">768</address>"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("Expected %s in %s", want, rec.Body)
+		}
+	}
+
+	proppatch := func(props string) string {
+		return `<?xml version="1.0"?><D:propertyupdate xmlns:D="DAV:" xmlns:dos="` + dosNamespace + `">` +
+			`<D:set><D:prop>` + props + `</D:prop></D:set></D:propertyupdate>`
+	}
+	rec = serve("PROPPATCH", "/DISK/PROG", nil, proppatch(`<dos:type>T</dos:type><dos:locked>true</dos:locked>`))
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "200 OK") {
+		t.Fatal("Expected the patch to succeed, got", rec.Code, rec.Body)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "*T 002 PROG") {
+		t.Fatal("Expected PROG to be a locked TEXT file:", catalog)
+	}
+
+	rec = serve("PROPPATCH", "/DISK/PROG", nil, proppatch(`<dos:type>B</dos:type><dos:sectors>9</dos:sectors>`))
+	if body := rec.Body.String(); !strings.Contains(body, "403 Forbidden") || !strings.Contains(body, "424 Failed Dependency") {
+		t.Fatal("Expected the sectors to be refused and the type left alone:", body)
+	}
+	rec = serve("PROPPATCH", "/DISK/PROG", nil, proppatch(`<dos:type>Q</dos:type>`))
+	if body := rec.Body.String(); !strings.Contains(body, "409 Conflict") {
+		t.Fatal("Expected an unknown type to be refused:", body)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "*T 002 PROG") {
+		t.Fatal("Expected PROG to be unchanged:", catalog)
+	}
+}
+
+func TestETagsAndContentTypes(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	fs := newFileSystem(path)
+	serve := davServer(fs)
+
+	etag := func(name string) string {
+		rec := serve("PROPFIND", name, map[string]string{"Depth": "0"},
+			`<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop><D:getetag/></D:prop></D:propfind>`)
+		_, after, _ := strings.Cut(rec.Body.String(), "getetag>")
+		tag, _, _ := strings.Cut(after, "<")
+		if tag == "" {
+			t.Fatal("Expected an ETag for", name, rec.Body)
+		}
+		return tag
+	}
+	hello, prog, catalog := etag("/DISK/HELLO"), etag("/DISK/PROG"), etag("/DISK/_dos/CATALOG.txt")
+	if hello == prog {
+		t.Fatal("Expected different files to have different ETags:", hello)
+	}
+	if _, err := fs.OpenFile(context.Background(), "/DISK/PROG,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if got := etag("/DISK/HELLO"); got != hello {
+		t.Fatalf("Expected HELLO's ETag to stay %s, got %s", hello, got)
+	}
+	if got := etag("/DISK/_dos/CATALOG.txt"); got == catalog {
+		t.Fatal("Expected the catalog's ETag to change with it:", got)
+	}
+
+	for name, want := range map[string]string{
+		"/DISK/PROG":                 "application/octet-stream",
+		"/DISK/_dos/CATALOG.txt":     "text/plain; charset=utf-8",
+		"/DISK/_dos/CATALOG.json":    "application/json",
+		"/DISK/_dos/export/DISK.zip": "application/zip",
+	} {
+		rec := serve("PROPFIND", name, map[string]string{"Depth": "0"},
+			`<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop><D:getcontenttype/></D:prop></D:propfind>`)
+		if !strings.Contains(rec.Body.String(), "getcontenttype>"+want+"<") {
+			t.Errorf("Expected %s to be %s: %s", name, want, rec.Body)
+		}
+		if got := serve("GET", name, nil, "").Header().Get("Content-Type"); got != want {
+			t.Errorf("Expected GET %s to be %s, got %s", name, want, got)
+		}
+	}
+}
+
+func TestQuota(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	serve := davServer(newFileSystem(path))
+
+	rec := serve("PROPFIND", "/DISK/", map[string]string{"Depth": "0"}, `<?xml version="1.0"?><D:propfind xmlns:D="DAV:">`+
+		`<D:prop><D:quota-available-bytes/><D:quota-used-bytes/></D:prop></D:propfind>`)
+	// 492 sectors are free: tracks 3-34 less the catalog track and 4 for files.
+	for _, want := range []string{"quota-available-bytes>125952<", "quota-used-bytes>17408<"} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("Expected %s in %s", want, rec.Body)
+		}
+	}
+
+	for _, body := range []string{"", `<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`} {
+		rec = serve("PROPFIND", "/DISK/", map[string]string{"Depth": "0"}, body)
+		if rec.Code != http.StatusMultiStatus || strings.Contains(rec.Body.String(), "quota") {
+			t.Fatalf("Expected no quota properties among all properties, got %d %s", rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestFileTimes(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	fs := newFileSystemOptions(Options{FileTimes: true}, path)
+	before, err := fs.Stat(context.Background(), "/DISK/PROG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.OpenFile(context.Background(), "/DISK/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new server reads the times back from the sidecar.
+	fs = newFileSystemOptions(Options{FileTimes: true}, path)
+	hello, err := fs.Stat(context.Background(), "/DISK/HELLO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := fs.Stat(context.Background(), "/DISK/PROG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prog.ModTime().Equal(before.ModTime()) || !hello.ModTime().After(before.ModTime()) {
+		t.Fatalf("Expected only HELLO to have changed since %v: HELLO %v, PROG %v",
+			before.ModTime(), hello.ModTime(), prog.ModTime())
+	}
+
+	rec := davServer(fs)("PROPFIND", "/DISK/HELLO", map[string]string{"Depth": "0"},
+		`<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop><D:creationdate/></D:prop></D:propfind>`)
+	if want := "creationdate>" + before.ModTime().UTC().Format(time.RFC3339) + "<"; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("Expected HELLO to have been created by the disk's time, %s, got %s", want, rec.Body)
+	}
+}
+
+func TestVolumeAndGreetingFiles(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.DSK", testFiles...)
+	fs := newFileSystem(path)
+	serve := davServer(fs)
+
+	if volume := readString(t, fs, "/DISK/_dos/VOLUME.txt"); volume != "254\n" {
+		t.Fatalf("Expected volume 254, got %q", volume)
+	}
+	if rec := serve("PUT", "/DISK/_dos/VOLUME.txt", nil, "100\n"); rec.Code != http.StatusCreated {
+		t.Fatal("Expected the volume to be changed, got", rec.Code)
+	}
+	if catalog := readString(t, fs, "/DISK/_dos/CATALOG.txt"); !strings.Contains(catalog, "DISK VOLUME 100") {
+		t.Fatal("Expected volume 100 in the catalog:", catalog)
+	}
+	for value, want := range map[string]string{"300": "from 1 to 254", "X": "not a number"} {
+		rec := serve("PUT", "/DISK/_dos/VOLUME.txt", nil, value)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("Expected volume %s to be refused with 400 Bad Request, got %d %s", value, rec.Code, rec.Body)
+		}
+	}
+
+	// The test disk has no DOS image, so it has no greeting program either.
+	if greeting := readString(t, fs, "/DISK/_dos/GREETING.txt"); greeting != "" {
+		t.Fatalf("Expected no greeting program, got %q", greeting)
+	}
+	rec := serve("PUT", "/DISK/_dos/GREETING.txt", nil, "MENU")
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "no DOS image") {
+		t.Fatal("Expected the greeting program to be refused with 409 Conflict, got", rec.Code, rec.Body)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	one := dsktest.Write(t, dir, "ONE.dsk", testFiles...)
+	two := dsktest.Write(t, dir, "TWO.dsk", append(testFiles, dsktest.File{Name: "NEW", Type: dsktest.Text, Data: []byte{0xC1}})...)
+	fs := newFileSystem(one, two)
+
+	diffs, err := fs.OpenFile(context.Background(), "/_diff", 0, os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, err := diffs.Readdir(0); err != nil || len(files) != 0 {
+		t.Fatal("Expected _diff to list nothing, got", files, err)
+	}
+	for _, name := range []string{"/_diff/ONE..ONE.txt", "/_diff/ONE..THREE.txt", "/_diff/ONE..TWO"} {
+		if _, err := fs.Stat(context.Background(), name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected no %s, got %v", name, err)
+		}
+	}
+	if info, err := fs.Stat(context.Background(), "/_diff/TWO..ONE.txt"); err != nil || info.Name() != "TWO..ONE.txt" {
+		t.Fatal("Expected a diff each way, got", info, err)
+	}
+	folded := newFileSystemOptions(Options{FoldNames: true}, one, two)
+	if info, err := folded.Stat(context.Background(), "/_diff/one..Two.TXT"); err != nil || info.Name() != "ONE..TWO.txt" {
+		t.Fatal("Expected a folded name to find the diff, got", info, err)
+	}
+	if diff := readString(t, fs, "/_diff/ONE..TWO.txt"); !strings.Contains(diff, "  + NEW (T, 2 sectors)\n") {
+		t.Fatal("Expected NEW to be added:", diff)
+	}
+
+	if _, err := fs.OpenFile(context.Background(), "/TWO/HELLO,locked", 0, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if diff := readString(t, fs, "/_diff/ONE..TWO.txt"); !strings.Contains(diff, "  ~ HELLO: unlocked -> locked\n") {
+		t.Fatal("Expected the diff to follow the change to TWO:", diff)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dir := t.TempDir()
+	one := dsktest.Write(t, dir, "ONE.dsk", testFiles...)
+	two := dsktest.Write(t, dir, "TWO.dsk",
+		dsktest.File{Name: "NOTES", Type: dsktest.Text, Data: []byte{0xC8, 0xC5, 0xCC, 0xCC, 0xCF, 0x8D}}, // HELLO
+		dsktest.File{Name: "CODE", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, []byte{0xEA, 0xA9, 0xC1, 0x60})},
+	)
+	fs := newFileSystem(one, two)
+
+	index := readString(t, fs, "/_search/hello/INDEX.txt")
+	for _, want := range []string{"ONE\tHELLO\tname\t0\n", "TWO\tNOTES\tlisting\t0\n"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("Expected %q in the index:\n%s", want, index)
+		}
+	}
+	if _, err := fs.Stat(context.Background(), "/_search/hello/TWO/NOTES"); err != nil {
+		t.Error("Expected NOTES among the results:", err)
+	}
+
+	// $A9C1 is in the contents of PROG on ONE, after its header, and of CODE.
+	index = readString(t, fs, "/_search/$A9C1/INDEX.txt")
+	for _, want := range []string{"ONE\tPROG\tbytes\t4\n", "TWO\tCODE\tbytes\t5\n"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("Expected %q in the index:\n%s", want, index)
+		}
+	}
+	if got := readString(t, fs, "/_search/$A9C1/TWO/CODE"); got != string(dsktest.BinaryData(0x0300, []byte{0xEA, 0xA9, 0xC1, 0x60})) {
+		t.Errorf("Expected the result to be CODE itself, got %q", got)
+	}
+
+	if _, err := fs.Stat(context.Background(), "/_search/$XYZ"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected a bad hex query to find nothing, got", err)
+	}
+}
+
+func TestSearchDamagedCatalog(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	// Link the first catalog sector back to itself.
+	copy(image[(17*16+15)*256+0x01:], []byte{17, 15})
+	path := filepath.Join(t.TempDir(), "DISK.DSK")
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fs := newFileSystem(path)
+
+	if index := readString(t, fs, "/_search/hello/INDEX.txt"); !strings.Contains(index, "DISK\tHELLO\tname\t0\n") {
+		t.Errorf("Expected HELLO to be found before the damage:\n%s", index)
+	}
+	if got := readString(t, fs, "/_search/hello/DISK.error.txt"); !strings.Contains(got, "corrupt catalog") {
+		t.Error("Expected the damage to be reported, got", got)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	dir := t.TempDir()
+	path := dsktest.Write(t, dir, "DISK.DSK", testFiles...)
+	image, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := filepath.Join(dir, "hashes.tsv")
+	if err := os.WriteFile(db, []byte(fmt.Sprintf("name\tsha256\nTest Disk\t%x\n", sha256.Sum256(image))), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newFileSystemOptions(Options{HashDB: db}, path)
+	if report := readString(t, fs, "/DISK/_dos/IDENTIFY.txt"); !strings.Contains(report, "Disk DISK (DISK.DSK): Test Disk\n") {
+		t.Errorf("Expected the disk to be identified, got:\n%s", report)
+	}
+	rec := davServer(fs)("PROPFIND", "/DISK/", map[string]string{"Depth": "0"}, `<?xml version="1.0"?><D:propfind xmlns:D="DAV:">`+
+		`<D:prop><identity xmlns="`+dosNamespace+`"/></D:prop></D:propfind>`)
+	if !strings.Contains(rec.Body.String(), ">Test Disk<") {
+		t.Errorf("Expected the identity property, got %s", rec.Body)
+	}
+
+	// Without a hash database, there is nothing to identify disks by.
+	if _, err := newFileSystem(path).Stat(context.Background(), "/DISK/_dos/IDENTIFY.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected no IDENTIFY.txt, got", err)
+	}
+}
+
+// davServer returns a function that serves a WebDAV request from fs.
+func davServer(fs *dos33FS) func(method, target string, header map[string]string, body string) *httptest.ResponseRecorder {
+	handler := fs.wrap(&webdav.Handler{FileSystem: fs, LockSystem: fs.locks})
+	return func(method, target string, header map[string]string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+// BenchmarkPropfind times PROPFIND requests against a library of 100 disks.
+func BenchmarkPropfind(b *testing.B) {
+	dir := b.TempDir()
+	var disks []string
+	for i := 0; i < 100; i++ {
+		disks = append(disks, dsktest.Write(b, dir, fmt.Sprintf("DISK%03d.DSK", i), testFiles...))
+	}
+	handler := &webdav.Handler{
+		FileSystem: newFileSystem(disks...),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	for _, bm := range []struct{ name, path, depth string }{
+		{"Root", "/", "1"},
+		{"Disk", "/DISK042/", "1"},
+		{"File", "/DISK042/HELLO", "0"},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest("PROPFIND", bm.path, nil)
+				req.Header.Set("Depth", bm.depth)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != http.StatusMultiStatus {
+					b.Fatal("Expected 207 Multi-Status, got", rec.Code)
+				}
+			}
+		})
+	}
+}
+
+// readString returns the entire contents of the file at name.
+func readString(t *testing.T, fs *dos33FS, name string) string {
+	t.Helper()
+	file, err := fs.OpenFile(context.Background(), name, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf)
+}
+
 func name(info fs.FileInfo) string { return info.Name() }
 
 // transform maps items from type T to result type R using fn.
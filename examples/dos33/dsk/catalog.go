@@ -0,0 +1,103 @@
+package dsk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+var errCorruptEntry = errors.New("corrupt catalog entry")
+
+// errCorruptCatalog is why the chain of catalog sectors cannot be followed.
+var errCorruptCatalog = errors.New("corrupt catalog")
+
+// CatalogRecord describes a catalog entry for machines rather than people.
+type CatalogRecord struct {
+	RawName      string  `json:"rawName"`     // the name as stored, in hex
+	Name         string  `json:"name"`        // as in paths, see [Diskette.PathNames]
+	EscapedName  string  `json:"escapedName"` // as [Filename.ANSIEscaped]
+	Type         string  `json:"type"`        // as shown by CATALOG, such as "A" or "B"
+	Locked       bool    `json:"locked"`
+	Deleted      bool    `json:"deleted"`
+	Sectors      uint16  `json:"sectors"`     // as recorded in the catalog
+	TSListTrack  uint    `json:"tsListTrack"` // location of the first T/S list
+	TSListSector uint    `json:"tsListSector"`
+	Length       int     `json:"length"`            // of the contents, see [Diskette.Contents]
+	Address      *uint16 `json:"address,omitempty"` // load address of a BINARY file
+	SHA256       string  `json:"sha256"`            // of the contents, in hex
+}
+
+// catalogColumns are the CSV column names of the fields of a CatalogRecord.
+var catalogColumns = []string{
+	"rawName", "name", "escapedName", "type", "locked", "deleted",
+	"sectors", "tsListTrack", "tsListSector", "length", "address", "sha256",
+}
+
+// CatalogRecords describes every entry in the catalog of dsk, including
+// deleted files. The length and hash of a file whose contents cannot be read,
+// as is often the case once deleted, are left empty.
+func (dsk *Diskette) CatalogRecords() []CatalogRecord {
+	var records []CatalogRecord
+	names := dsk.PathNames()
+	for i, file := range dsk.Catalog() {
+		name := file.Name()
+		track, sector := file.FirstTSList()
+		record := CatalogRecord{
+			RawName:      hex.EncodeToString(name),
+			Name:         names[i],
+			EscapedName:  name.ANSIEscaped(),
+			Type:         file.Type().String(),
+			Locked:       file.IsLocked(),
+			Deleted:      file.IsDeleted(),
+			Sectors:      file.SectorsUsed(),
+			TSListTrack:  track,
+			TSListSector: sector,
+		}
+		if data, address, err := dsk.Contents(file); err == nil {
+			sum := sha256.Sum256(data)
+			record.Length = len(data)
+			record.SHA256 = hex.EncodeToString(sum[:])
+			if file.IsBinary() {
+				record.Address = &address
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// CatalogJSON returns the catalog of dsk as a JSON array of [CatalogRecord].
+func CatalogJSON(dsk *Diskette) string {
+	records := dsk.CatalogRecords()
+	if records == nil {
+		records = []CatalogRecord{}
+	}
+	buf, _ := json.MarshalIndent(records, "", "  ")
+	return string(buf) + "\n"
+}
+
+// CatalogCSV returns the catalog of dsk as CSV with a header row, one row per
+// [CatalogRecord].
+func CatalogCSV(dsk *Diskette) string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(catalogColumns)
+	for _, r := range dsk.CatalogRecords() {
+		address := ""
+		if r.Address != nil {
+			address = strconv.Itoa(int(*r.Address))
+		}
+		w.Write([]string{
+			r.RawName, r.Name, r.EscapedName, r.Type,
+			strconv.FormatBool(r.Locked), strconv.FormatBool(r.Deleted),
+			strconv.Itoa(int(r.Sectors)), strconv.Itoa(int(r.TSListTrack)), strconv.Itoa(int(r.TSListSector)),
+			strconv.Itoa(r.Length), address, r.SHA256,
+		})
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,82 @@
+package dsk
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestCatalogRecords(t *testing.T) {
+	code := []byte{0xA9, 0xC1, 0x60}
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "PROG", Type: dsktest.Binary, Locked: true, Data: dsktest.BinaryData(0x0300, code)},
+		dsktest.File{RawName: []byte{0x08, 0xC9}, Type: dsktest.Text, Data: []byte{0xC8, 0xC9, 0x8D, 0}},
+		dsktest.File{Name: "GONE", Type: dsktest.Text, Deleted: true, Data: []byte{0x8D, 0}},
+	))
+
+	records := dsk.CatalogRecords()
+	if len(records) != 3 {
+		t.Fatal("Expected 3 records, got", len(records))
+	}
+
+	prog := records[0]
+	sum := sha256.Sum256(code)
+	if prog.Name != "PROG" || prog.Type != "B" || !prog.Locked || prog.Deleted ||
+		prog.Address == nil || *prog.Address != 0x0300 || prog.Length != 3 || prog.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatalf("Unexpected record for PROG: %+v", prog)
+	}
+	if prog.RawName != hex.EncodeToString([]byte("\xD0\xD2\xCF\xC7")) {
+		t.Fatal("Expected the raw name in hex, got", prog.RawName)
+	}
+	if prog.TSListTrack == 0 {
+		t.Fatal("Expected the location of the T/S list")
+	}
+
+	inverse := records[1]
+	if inverse.Name != "H\u20deI" || inverse.EscapedName == inverse.Name || inverse.RawName != "08c9" {
+		t.Fatalf("Unexpected record for inverse name: %+v", inverse)
+	}
+	if !records[2].Deleted {
+		t.Fatal("Expected the deleted file to be included")
+	}
+	if inverse.Address != nil {
+		t.Fatal("Expected no address for a TEXT file, got", *inverse.Address)
+	}
+}
+
+func TestCatalogZeroAddress(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "ZERO", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0000, []byte{0x60})},
+	))
+	if !strings.Contains(CatalogJSON(dsk), `"address": 0,`) {
+		t.Error("Expected a load address of 0 in the JSON, got", CatalogJSON(dsk))
+	}
+	if rows, _ := csv.NewReader(strings.NewReader(CatalogCSV(dsk))).ReadAll(); len(rows) != 2 || rows[1][10] != "0" {
+		t.Error("Expected a load address of 0 in the CSV, got", rows)
+	}
+}
+
+func TestCatalogJSONAndCSV(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254, testFiles...))
+
+	var records []CatalogRecord
+	if err := json.Unmarshal([]byte(CatalogJSON(dsk)), &records); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(testFiles) || records[0].Name != testFiles[0].Name {
+		t.Fatalf("Expected a record per file, got %+v", records)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(CatalogCSV(dsk))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(testFiles)+1 || rows[0][1] != "name" || rows[1][1] != testFiles[0].Name {
+		t.Fatalf("Expected a header and a row per file, got %v", rows)
+	}
+}
@@ -0,0 +1,254 @@
+package dsk
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/// Disk Diffs
+/*
+Diff compares two disks, such as two revisions of the same software, at three
+levels:
+
+  Catalog  the files added, removed, retyped, locked or unlocked
+  Files    for each file on both disks with other contents, a line diff of the
+           listings of BASIC and TEXT files, or the ranges of bytes that
+           differ in any other file
+  Sectors  the track and sector of every sector that differs
+
+Files are matched by their path names, as [Diskette.PathNames] gives them.
+Deleted files are left out. If a disk's catalog is damaged, the Catalog section
+says where, and only the files before the damage are compared.
+*/
+
+const (
+	maxDiffRanges = 20        // byte ranges shown per file
+	maxDiffCells  = 4_000_000 // lines × lines compared per file
+)
+
+// Diff describes how disk b differs from disk a.
+func Diff(a, b *Diskette) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", a.Name(), b.Name())
+
+	filesA, errA := a.filesByPathName()
+	filesB, errB := b.filesByPathName()
+	sb.WriteString("\nCatalog\n")
+	catalog := sb.Len()
+	for _, err := range []error{errA, errB} {
+		if err != nil {
+			fmt.Fprintf(&sb, "  ! %v; only the files before it are compared\n", err)
+		}
+	}
+	for _, name := range filesA.names {
+		if _, ok := filesB.files[name]; !ok {
+			file := filesA.files[name]
+			fmt.Fprintf(&sb, "  - %s (%s, %d sectors)\n", name, file.Type(), file.SectorsUsed())
+		}
+	}
+	for _, name := range filesB.names {
+		file := filesB.files[name]
+		prev, ok := filesA.files[name]
+		switch {
+		case !ok:
+			fmt.Fprintf(&sb, "  + %s (%s, %d sectors)\n", name, file.Type(), file.SectorsUsed())
+		case prev.Type() != file.Type():
+			fmt.Fprintf(&sb, "  ~ %s: type %s -> %s\n", name, prev.Type(), file.Type())
+		}
+		if ok && prev.IsLocked() != file.IsLocked() {
+			fmt.Fprintf(&sb, "  ~ %s: %s -> %s\n", name, lockedWord(prev), lockedWord(file))
+		}
+	}
+	noneIfEmpty(&sb, catalog)
+
+	sb.WriteString("\nFiles\n")
+	files := sb.Len()
+	for _, name := range filesB.names {
+		if prev, ok := filesA.files[name]; ok {
+			diffFile(&sb, name, a, prev, b, filesB.files[name])
+		}
+	}
+	noneIfEmpty(&sb, files)
+
+	sb.WriteString("\nSectors\n")
+	diffSectors(&sb, a, b)
+	return sb.String()
+}
+
+// pathNamedFiles are the files on a disk that are not deleted, by path name.
+type pathNamedFiles struct {
+	names []string // in catalog order
+	files map[string]FileEntry
+}
+
+func (dsk *Diskette) filesByPathName() (pathNamedFiles, error) {
+	named := pathNamedFiles{files: make(map[string]FileEntry)}
+	names := dsk.PathNames()
+	catalog, err := dsk.ReadCatalog()
+	for i, file := range catalog {
+		if !file.IsDeleted() {
+			named.names = append(named.names, names[i])
+			named.files[names[i]] = file
+		}
+	}
+	return named, err
+}
+
+func lockedWord(file FileEntry) string {
+	if file.IsLocked() {
+		return "locked"
+	}
+	return "unlocked"
+}
+
+// noneIfEmpty notes that a section starting at start has nothing in it.
+func noneIfEmpty(sb *strings.Builder, start int) {
+	if sb.Len() == start {
+		sb.WriteString("  none\n")
+	}
+}
+
+// diffFile describes how the contents of file b differ from those of file a.
+func diffFile(sb *strings.Builder, name string, a *Diskette, fileA FileEntry, b *Diskette, fileB FileEntry) {
+	dataA, errA := a.ReadAll(fileA)
+	dataB, errB := b.ReadAll(fileB)
+	switch {
+	case errA != nil || errB != nil:
+		fmt.Fprintf(sb, "  %s: cannot be compared: %v\n", name, firstError(errA, errB))
+		return
+	case bytes.Equal(dataA, dataB):
+		return
+	}
+
+	listA, okA, errA := a.Listing(fileA)
+	listB, okB, errB := b.Listing(fileB)
+	if okA && okB && errA == nil && errB == nil {
+		fmt.Fprintf(sb, "  %s (listing)\n", name)
+		diffLines(sb, strings.Split(listA, "\n"), strings.Split(listB, "\n"))
+		return
+	}
+	fmt.Fprintf(sb, "  %s (bytes)\n", name)
+	diffBytes(sb, dataA, dataB)
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffLines writes the hunks of lines that differ between a and b, as in a
+// unified diff without context.
+func diffLines(sb *strings.Builder, a, b []string) {
+	if len(a)*len(b) > maxDiffCells {
+		fmt.Fprintf(sb, "    too long to compare: %d lines -> %d lines\n", len(a), len(b))
+		return
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:], b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		if i < len(a) && j < len(b) && a[i] == b[j] {
+			i, j = i+1, j+1
+			continue
+		}
+		startA, startB := i, j
+		for (i < len(a) || j < len(b)) && !(i < len(a) && j < len(b) && a[i] == b[j]) {
+			if j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]) {
+				i++
+			} else {
+				j++
+			}
+		}
+		fmt.Fprintf(sb, "    @@ -%s +%s @@\n", hunkRange(startA, i-startA), hunkRange(startB, j-startB))
+		for _, line := range a[startA:i] {
+			fmt.Fprintf(sb, "    -%s\n", line)
+		}
+		for _, line := range b[startB:j] {
+			fmt.Fprintf(sb, "    +%s\n", line)
+		}
+	}
+}
+
+// hunkRange returns the range of count lines from the index start as a
+// unified diff shows it, numbering lines from 1.
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffBytes writes the ranges of bytes that differ between a and b.
+func diffBytes(sb *strings.Builder, a, b []byte) {
+	var ranges [][2]int
+	for i := 0; i < min(len(a), len(b)); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1][1] == i {
+			ranges[n-1][1] = i + 1
+		} else {
+			ranges = append(ranges, [2]int{i, i + 1})
+		}
+	}
+	for i, r := range ranges {
+		if i == maxDiffRanges {
+			fmt.Fprintf(sb, "    and %d more ranges\n", len(ranges)-i)
+			break
+		}
+		if r[1]-r[0] == 1 {
+			fmt.Fprintf(sb, "    $%04X: $%02X -> $%02X\n", r[0], a[r[0]], b[r[0]])
+		} else {
+			fmt.Fprintf(sb, "    $%04X-$%04X differ\n", r[0], r[1]-1)
+		}
+	}
+	if len(a) != len(b) {
+		fmt.Fprintf(sb, "    length %d -> %d\n", len(a), len(b))
+	}
+}
+
+// diffSectors writes the sectors that differ between a and b.
+func diffSectors(sb *strings.Builder, a, b *Diskette) {
+	if a.SectorsPerTrack() != b.SectorsPerTrack() || a.SectorSize() != b.SectorSize() {
+		fmt.Fprintf(sb, "  cannot be compared: %d sectors per track -> %d\n", a.SectorsPerTrack(), b.SectorsPerTrack())
+		return
+	}
+	changed := 0
+	for t := uint(0); t < min(a.NumTracks(), b.NumTracks()); t++ {
+		for s := uint(0); s < a.SectorsPerTrack(); s++ {
+			if !bytes.Equal(a.rawSector(t, s), b.rawSector(t, s)) {
+				fmt.Fprintf(sb, "  T%d S%d\n", t, s)
+				changed++
+			}
+		}
+	}
+	if a.NumTracks() != b.NumTracks() {
+		fmt.Fprintf(sb, "  %d tracks -> %d\n", a.NumTracks(), b.NumTracks())
+	}
+	if changed == 0 && a.NumTracks() == b.NumTracks() {
+		sb.WriteString("  none\n")
+	} else {
+		fmt.Fprintf(sb, "  %d sectors differ\n", changed)
+	}
+}
@@ -0,0 +1,59 @@
+package dsk
+
+import (
+	"strings"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+// hiText returns lines as the contents of a TEXT file.
+func hiText(lines ...string) []byte {
+	var data []byte
+	for _, line := range lines {
+		for _, c := range []byte(line + "\r") {
+			data = append(data, c|0x80)
+		}
+	}
+	return data
+}
+
+func TestDiff(t *testing.T) {
+	code := []byte{0xA9, 0xC1, 0x20, 0xED, 0xFD, 0x60}
+	changed := []byte{0xA9, 0xC2, 0x20, 0xED, 0xFD, 0x60, 0xEA}
+	a := loadImage(t, "OLD.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "README", Type: dsktest.Text, Data: hiText("ONE", "TWO", "THREE")},
+		dsktest.File{Name: "PROG", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, code)},
+		dsktest.File{Name: "GONE", Type: dsktest.Text, Data: hiText("BYE")},
+		dsktest.File{Name: "DATA", Type: dsktest.Text, Data: hiText("SAME")},
+	))
+	b := loadImage(t, "NEW.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "README", Type: dsktest.Text, Data: hiText("ONE", "2", "THREE", "FOUR")},
+		dsktest.File{Name: "PROG", Type: dsktest.Binary, Locked: true, Data: dsktest.BinaryData(0x0300, changed)},
+		dsktest.File{Name: "DATA", Type: dsktest.Binary, Data: hiText("SAME")},
+		dsktest.File{Name: "NEW", Type: dsktest.Text, Data: hiText("HI")},
+	))
+
+	diff := Diff(a, b)
+	for _, want := range []string{
+		"--- OLD\n+++ NEW\n",
+		"  - GONE (T, 2 sectors)\n",
+		"  + NEW (T, 2 sectors)\n",
+		"  ~ DATA: type T -> B\n",
+		"  ~ PROG: unlocked -> locked\n",
+		"  README (listing)\n    @@ -2 +2 @@\n    -TWO\n    +2\n    @@ -3,0 +4 @@\n    +FOUR\n",
+		"  PROG (bytes)\n    $0002: $06 -> $07\n    $0005: $C1 -> $C2\n    length 10 -> 11\n",
+		"\nSectors\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in the diff:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "DATA (") {
+		t.Errorf("Expected DATA's contents to be the same:\n%s", diff)
+	}
+
+	if same := Diff(a, a); !strings.Contains(same, "Catalog\n  none\n\nFiles\n  none\n\nSectors\n  none\n") {
+		t.Errorf("Expected a disk to be the same as itself:\n%s", same)
+	}
+}
@@ -1,7 +1,6 @@
 package dsk
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -9,18 +8,30 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 // Diskette represents an Apple DOS 3.3 formatted disk image.
 type Diskette struct {
-	hostFile *os.File
-	path     string // Path on host
+	hostFile *os.File  // nil if the image is not a file of its own, as in a zip
+	path     string    // Path on host
+	modTime  time.Time // when the image was modified, if there is no hostFile
+	archive  string    // Path on host of the archive holding the image, if any
+	member   []byte    // the image file as it is in the archive, if it is in one
 	name     string
 	bytes    []byte
 	readonly bool
 	vtoc     []byte
+	order    SectorOrder
+	format   imageDecoder
+	comment  string
+	overlay  *overlay // nil unless changes go to a sidecar file
+	meta     *meta    // nil unless file times are kept, see [Diskette.UseFileTimes]
+
+	generation atomic.Uint64 // see [Diskette.Generation]
 }
 
 func (dsk *Diskette) Name() string          { return dsk.name }
@@ -29,66 +40,58 @@ func (dsk *Diskette) SectorSize() uint16    { return word(dsk.vtoc[0x36:]) }
 func (dsk *Diskette) SectorsPerTrack() uint { return uint(dsk.vtoc[0x35]) }
 func (dsk *Diskette) Volume() uint          { return uint(dsk.vtoc[0x06]) }
 
+// SetName changes the name the disk goes by, as when another disk has its name.
+func (dsk *Diskette) SetName(name string) { dsk.name = name }
+
+// Generation counts the changes made to the disk. It goes up whenever the
+// contents of the disk may have changed, so anything derived from them can be
+// kept until it does.
+func (dsk *Diskette) Generation() uint64 { return dsk.generation.Load() }
+
+// Comment returns the comment stored with the disk image, if its format has one.
+func (dsk *Diskette) Comment() string { return dsk.comment }
+
 func (dsk *Diskette) ModTime() time.Time {
-	fi, err := dsk.hostFile.Stat()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "stat err %v\n", err)
-		return time.Time{}
+	hostTime := dsk.modTime
+	if dsk.hostFile != nil {
+		fi, err := dsk.hostFile.Stat()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stat err %v\n", err)
+			return time.Time{}
+		}
+		hostTime = fi.ModTime()
 	}
-	return fi.ModTime()
+	if dsk.overlay != nil {
+		if modTime, ok := dsk.overlay.modTime(); ok && modTime.After(hostTime) {
+			return modTime
+		}
+	}
+	return hostTime
 }
 
+// ReadAll returns the contents of file. See [Diskette.Open] to read them a
+// little at a time.
 func (dsk *Diskette) ReadAll(file FileEntry) ([]byte, error) {
-	readHeader := false
-	switch file.Type() {
-	case ftBinary, ftRelocatable:
-		readHeader = true
-	case ftText, ftIntegerBasic, ftApplesoftBasic, ftS, ftA, ftB:
-		readHeader = false
-	default:
-		panic("ReadAll: switch is non-exhaustive")
-	}
-
-	cap := file.SectorsUsed() * dsk.SectorSize()
-	p := make([]byte, 0, cap)
-	buf := bytes.NewBuffer(p)
-
-	if readHeader {
-		var length uint16
-		for s, data := range dsk.DataSectors(file) {
-			i := 0
-			if s == 0 {
-				// First sector starts with 4-byte header (address + length)
-				address := word(data[0x00:])
-				length = word(data[0x02:])
-				fmt.Fprintf(os.Stderr, "%s - Address = $%.4X  Length = $%.4X\n",
-					file.Name().PathSafe(), address, length)
-				i = 4
-				buf.Write(data[:i])
-			}
-			for ; i < len(data) && length > 0; i++ {
-				buf.WriteByte(data[i])
-				length--
-			}
-			if length == 0 {
-				break
-			}
-		}
-	} else {
-		for _, data := range dsk.DataSectors(file) {
-			buf.Write(data)
-		}
+	r, err := dsk.Open(file)
+	if err != nil {
+		return nil, err
 	}
-
-	return buf.Bytes(), nil
+	buf := make([]byte, r.Size())
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, err
+	}
+	return buf, nil
 }
 
 func (dsk *Diskette) Delete(file FileEntry) error {
-	if dsk.readonly || file.IsDeleted() || file.IsLocked() {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
+	if file.IsDeleted() || file.IsLocked() {
 		return os.ErrPermission
 	}
 	prev20 := file.delete()
-	if err := dsk.save(); err != nil {
+	if err := dsk.save(file); err != nil {
 		file.undelete(prev20)
 		return err
 	}
@@ -96,29 +99,64 @@ func (dsk *Diskette) Delete(file FileEntry) error {
 }
 
 func (dsk *Diskette) Lock(file FileEntry) error {
-	if dsk.readonly {
-		return os.ErrPermission
+	if !dsk.writable() {
+		return dsk.errReadonly()
 	}
 	file.lock()
-	if err := dsk.save(); err != nil {
+	if err := dsk.save(file); err != nil {
 		file.unlock()
 		return err
 	}
 	return nil
 }
 
-func (dsk *Diskette) Unlock(file FileEntry) error {
-	if dsk.readonly {
+// SetType changes the type of file, leaving it locked or unlocked.
+func (dsk *Diskette) SetType(file FileEntry, ft FileType) error {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
+	if file.IsDeleted() {
 		return os.ErrPermission
 	}
+	prev := file.Type()
+	file.setType(ft)
+	if err := dsk.save(file); err != nil {
+		file.setType(prev)
+		return err
+	}
+	return nil
+}
+
+func (dsk *Diskette) Unlock(file FileEntry) error {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
 	file.unlock()
-	if err := dsk.save(); err != nil {
+	if err := dsk.save(file); err != nil {
 		file.lock()
-		return nil
+		return err
 	}
 	return nil
 }
 
+// ImageFile returns the image file the disk was loaded from, as it is on the
+// host: the file itself or its member of the archive that holds it. Changes
+// kept in an overlay are not in it.
+func (dsk *Diskette) ImageFile() ([]byte, error) {
+	if dsk.hostFile == nil {
+		return dsk.member, nil
+	}
+	fi, err := dsk.hostFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, fi.Size())
+	if _, err := dsk.hostFile.ReadAt(buf, 0); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf, nil
+}
+
 // LoadDiskette reads the disk image at path.
 func LoadDiskette(path string) (*Diskette, error) {
 	file, err, readonly := tryOpenFileRW(path)
@@ -142,48 +180,121 @@ func LoadDiskette(path string) (*Diskette, error) {
 		return nil, fmt.Errorf("failed to read all bytes of %s; wanted %d, got %d", path, size, n)
 	}
 
+	dsk, err := decodeDiskette(path, buf, readonly)
+	if err != nil {
+		return nil, err
+	}
+	dsk.hostFile = file
+	return dsk, nil
+}
+
+// decodeDiskette returns the Diskette held by buf, the contents of the disk
+// image file at path.
+func decodeDiskette(path string, buf []byte, readonly bool) (*Diskette, error) {
+	format := formatByExt(path)
+	if _, ok := format.(imageEncoder); !ok {
+		readonly = true
+	}
+	image, err := format.decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	vtoc, err := findVTOC(image)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
 	name := filepath.Base(path)
 	ext := filepath.Ext(name)
 
-	return &Diskette{
-		hostFile: file,
+	dsk := &Diskette{
 		path:     path,
 		name:     name[:len(name)-len(ext)],
 		readonly: readonly,
-		bytes:    buf,
-		vtoc:     buf[vtocOffset(size):],
-	}, nil
+		bytes:    image,
+		vtoc:     vtoc,
+		order:    orderByExt(path),
+		format:   format,
+	}
+	if described, ok := format.(describedImage); ok {
+		info := described.info()
+		dsk.order = info.order
+		dsk.readonly = dsk.readonly || info.locked
+		dsk.comment = info.comment
+	}
+	dsk.order = dsk.detectOrder()
+	return dsk, nil
 }
 
-func (dsk *Diskette) save() error {
-	if dsk.readonly {
-		return os.ErrPermission
+// writable reports whether changes to dsk can be saved.
+func (dsk *Diskette) writable() bool { return dsk.overlay != nil || !dsk.readonly }
+
+// errReadonly explains why changes to dsk cannot be saved.
+func (dsk *Diskette) errReadonly() error {
+	if dsk.archive != "" {
+		return fmt.Errorf("%s is in the archive %s, which is never written; use an overlay to keep changes: %w",
+			dsk.name, dsk.archive, os.ErrPermission)
 	}
+	return os.ErrPermission
+}
 
-	fi, err := dsk.hostFile.Stat()
+// save writes the image, then records the time of the change to each of the
+// changed files.
+func (dsk *Diskette) save(changed ...FileEntry) error {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
+	dsk.generation.Add(1)
+	var err error
+	if dsk.overlay != nil {
+		err = dsk.overlay.save(dsk.bytes, int(dsk.SectorSize()))
+	} else {
+		err = dsk.writeHost()
+	}
+	if err != nil || dsk.meta == nil || len(changed) == 0 {
+		return err
+	}
+	dsk.meta.stamp(dsk, time.Now(), changed...)
+	// The change is saved, so its time is kept even if the sidecar cannot be
+	// written now; the next change writes it again.
+	if err := dsk.meta.write(); err != nil {
+		fmt.Fprintf(os.Stderr, "file times %s: %v\n", dsk.meta.path, err)
+	}
+	return nil
+}
+
+// writeHost writes the image back to the file on the host, in its format.
+func (dsk *Diskette) writeHost() error {
+	encoder, ok := dsk.format.(imageEncoder)
+	if !ok {
+		return fmt.Errorf("cannot write %s: %w", dsk.path, errors.ErrUnsupported)
+	}
+	buf, err := encoder.encode(dsk.bytes)
 	if err != nil {
 		return err
 	}
 
-	size := int(fi.Size())
+	size := len(buf)
 
-	if n, err := dsk.hostFile.WriteAt(dsk.bytes, 0); err != nil {
+	if n, err := dsk.hostFile.WriteAt(buf, 0); err != nil {
 		if !errors.Is(err, io.EOF) {
 			return err
 		}
 	} else if n != size {
 		return fmt.Errorf("failed to write all bytes of %s; wanted %d, got %d", dsk.path, size, n)
 	}
-	return nil
+	return dsk.hostFile.Truncate(int64(size))
 }
 
 func (dsk *Diskette) rawSector(track, sector uint) []byte {
-	if track > dsk.NumTracks() {
-		panic(fmt.Errorf("rawSector: track is too large; wanted %d or less, got %d", dsk.NumTracks(), track))
-	} else if sector > dsk.SectorsPerTrack() {
-		panic(fmt.Errorf("rawSector: sector is too large; wanted %d or less, got %d", dsk.SectorsPerTrack(), sector))
+	if track >= dsk.NumTracks() {
+		panic(fmt.Errorf("rawSector: track is too large; wanted less than %d, got %d", dsk.NumTracks(), track))
+	} else if sector >= dsk.SectorsPerTrack() {
+		panic(fmt.Errorf("rawSector: sector is too large; wanted less than %d, got %d", dsk.SectorsPerTrack(), sector))
 	}
-	offset := (track*dsk.SectorsPerTrack() + sector) * uint(dsk.SectorSize())
+	position := dsk.order.position(sector, dsk.SectorsPerTrack())
+	offset := (track*dsk.SectorsPerTrack() + position) * uint(dsk.SectorSize())
 	return dsk.bytes[offset:][:dsk.SectorSize()]
 }
 
@@ -212,7 +323,7 @@ $30    last track where sectors were allocated
 $31    direction of track allocation (+1 or -1)
 $32-33 not used
 $34    number of tracks per diskette (normally 35)
-$35    number of sectors per track (13 or 16)
+$35    number of sectors per track (13, 16, or 32)
 $36-37 number of bytes per sector (LO/HI format)
 $38-3B bit map of free sectors in track 0
 $3C-3F bit map of free sectors in track 1
@@ -255,64 +366,92 @@ func (dsk *Diskette) VTOCFile() string {
 	// 34 $22
 	// where 34 is vtoc[(Tracks per diskette)]-1
 	// and cols D,E, and F are only used if vtoc[(Sectors per track)] == 16
-	cols := int(uint8(vtoc[0x35]))
-	if cols > 16 {
-		cols = 16
-	}
+	// (or cols 10-1F if it is 32).
+	cols := int(dsk.SectorsPerTrack())
 
 	for i := 0; i < cols; i++ {
-		sb.WriteString(fmt.Sprintf(" %X", i))
-		if i == 7 {
+		sb.WriteString(fmt.Sprintf("%2X", i))
+		if i%8 == 7 && i+1 < cols {
 			sb.WriteRune(' ')
 		}
 	}
 	sb.WriteRune('\n')
 
-	bitmap := 0x38
-	rows := int(vtoc[0x34])
+	rows := int(dsk.NumTracks())
 	for r := 0; r < rows; r++ {
 		sb.WriteString(fmt.Sprintf(" %2d $%.2X ", r, r))
-		for c := 0; c < 8; c++ {
-			if free := vtoc[bitmap+1] & (0x1 << c); free != 0 {
+		for c := 0; c < cols; c++ {
+			if dsk.sectorFree(uint(r), uint(c)) {
 				sb.WriteString(" .")
 			} else {
 				sb.WriteString(" X")
 			}
-		}
-		sb.WriteRune(' ')
-		for c := 0; c < cols-8; c++ {
-			if free := vtoc[bitmap+0] & (0x1 << c); free != 0 {
-				sb.WriteString(" .")
-			} else {
-				sb.WriteString(" X")
+			if c%8 == 7 && c+1 < cols {
+				sb.WriteRune(' ')
 			}
 		}
 		sb.WriteRune('\n')
-		bitmap += 4
 	}
 
 	return sb.String()
 }
 
-// vtocOffset returns the offset based on the size of disk.
-// The VTOC sector is always Track 17, Sector 0.
-func vtocOffset(size int64) uint {
-	const (
-		d13Size = 116480 // 13 sectors * 256 bytes * 35 tracks = 116480
-		dskSize = 143360 // 16 sectors * 256 bytes * 35 tracks = 143360
+// sectorFree reports whether the VTOC bit map marks a sector as free.
+//
+// The four bytes of each track's bit map are a big-endian number in which the
+// highest sector is the highest bit. Tracks of up to 16 sectors only use the
+// first two bytes.
+func (dsk *Diskette) sectorFree(track, sector uint) bool {
+	width := uint(16)
+	if dsk.SectorsPerTrack() > 16 {
+		width = 32
+	}
+	bitmap := binary.BigEndian.Uint32(dsk.vtoc[0x38+4*track:])
+	return bitmap&(1<<(sector+32-width)) != 0
+}
 
-		d13VTOC = 0x0DD00 // 13 sectors * 256 bytes * 17 tracks
-		dskVTOC = 0x11000 // 16 sectors * 256 bytes * 17 tracks
+// FreeSectors returns the number of sectors the VTOC bit map marks as free.
+func (dsk *Diskette) FreeSectors() (free uint) {
+	for t := uint(0); t < dsk.NumTracks(); t++ {
+		for s := uint(0); s < dsk.SectorsPerTrack(); s++ {
+			if dsk.sectorFree(t, s) {
+				free++
+			}
+		}
+	}
+	return free
+}
+
+// findVTOC returns the VTOC sector of a DOS-ordered or ProDOS-ordered image.
+//
+// The VTOC sector is always Track 17, Sector 0, but where that is depends on
+// the number of sectors per track, so each possible geometry is tried until
+// the VTOC found there describes a geometry that fits in the image.
+func findVTOC(image []byte) ([]byte, error) {
+	const (
+		vtocTrack  = 17
+		sectorSize = 256
+		maxTracks  = (sectorSize - 0x38) / 4 // bitmaps that fit in the VTOC
 	)
 
-	switch size {
-	case d13Size:
-		return d13VTOC
-	case dskSize:
-		return dskVTOC
-	default:
-		panic(fmt.Errorf("vtocOffset: unexpected disk size; wanted %d or %d, got %d bytes", d13Size, dskSize, size))
+	for _, sectors := range []int{16, 13, 32} {
+		offset := vtocTrack * sectors * sectorSize
+		if offset+sectorSize > len(image) {
+			continue
+		}
+		vtoc := image[offset:][:sectorSize]
+		tracks := int(vtoc[0x34])
+		switch {
+		case int(vtoc[0x35]) != sectors:
+		case word(vtoc[0x36:]) != sectorSize:
+		case tracks <= vtocTrack || tracks > maxTracks:
+		case tracks*sectors*sectorSize > len(image):
+		default:
+			return vtoc, nil
+		}
 	}
+
+	return nil, fmt.Errorf("no DOS 3.3 VTOC found in %d byte image", len(image))
 }
 
 /// Catalog
@@ -338,8 +477,17 @@ $BA-DC Sixth file descriptive entry
 $DD-FF Seventh file descriptive entry
 */
 
-// Catalog returns all the files on disk. every file on disk and applies callback, stopping
-func (dsk *Diskette) Catalog() (entries []FileEntry) {
+// Catalog returns all the files on disk, as far as [Diskette.ReadCatalog] can
+// follow the chain of catalog sectors.
+func (dsk *Diskette) Catalog() []FileEntry {
+	entries, _ := dsk.ReadCatalog()
+	return entries
+}
+
+// ReadCatalog returns all the files on disk. If a catalog sector links to one
+// that is off the disk, or back to one already read, it returns the files
+// before the broken link and an error that says where it is.
+func (dsk *Diskette) ReadCatalog() (entries []FileEntry, err error) {
 	const (
 		offsetNextTrack  uint = 0x01
 		offsetNextSector uint = 0x02
@@ -347,9 +495,19 @@ func (dsk *Diskette) Catalog() (entries []FileEntry) {
 
 	entryOffsets := []uint8{0x0B, 0x2E, 0x51, 0x74, 0x97, 0xBA, 0xDD}
 
-	catalog := dsk.vtoc
+	seen := make(map[[2]uint]bool)
+	t, s := uint(dsk.vtoc[offsetNextTrack]), uint(dsk.vtoc[offsetNextSector])
 	for {
-		catalog = dsk.rawSector(uint(catalog[offsetNextTrack]), uint(catalog[offsetNextSector]))
+		if !dsk.validSector(t, s) {
+			return entries, fmt.Errorf("%s: %w: it links to track %d, sector %d, which is off the disk",
+				dsk.name, errCorruptCatalog, t, s)
+		} else if seen[[2]uint{t, s}] {
+			return entries, fmt.Errorf("%s: %w: it links back to track %d, sector %d",
+				dsk.name, errCorruptCatalog, t, s)
+		}
+		seen[[2]uint{t, s}] = true
+
+		catalog := dsk.rawSector(t, s)
 		for _, offset := range entryOffsets {
 			entry := FileEntry(catalog[offset:])
 			if entry.IsEmpty() {
@@ -362,13 +520,23 @@ func (dsk *Diskette) Catalog() (entries []FileEntry) {
 		if catalog[offsetNextTrack] == 0 {
 			break
 		}
+		t, s = uint(catalog[offsetNextTrack]), uint(catalog[offsetNextSector])
 	}
 
-	return
+	return entries, nil
 }
 
+// FindFile returns the file that filename names: its path name, as given by
+// [Diskette.PathNames], or failing that, its name as a string or with ANSI
+// escapes.
 func (dsk *Diskette) FindFile(filename string) FileEntry {
-	for _, entry := range dsk.Catalog() {
+	catalog := dsk.Catalog()
+	for i, name := range dsk.PathNames() {
+		if name == filename && !catalog[i].IsDeleted() {
+			return catalog[i]
+		}
+	}
+	for _, entry := range catalog {
 		if entry.Name().String() == filename {
 			return entry
 		}
@@ -442,7 +610,9 @@ type FileEntry []byte
 
 func (f FileEntry) IsEmpty() bool   { return f[0x00] == 0x00 }
 func (f FileEntry) IsDeleted() bool { return f[0x00] == 0xff }
-func (f FileEntry) firstTSList() (uint, uint) {
+
+// FirstTSList returns the track and sector of the file's first T/S list.
+func (f FileEntry) FirstTSList() (track, sector uint) {
 	if f.IsDeleted() {
 		return uint(f[0x20]), uint(f[0x01])
 	}
@@ -450,6 +620,7 @@ func (f FileEntry) firstTSList() (uint, uint) {
 }
 func (f FileEntry) IsLocked() bool { return f[0x02]&0x80 != 0 }
 func (f FileEntry) Type() FileType { return FileType(f[0x02] & 0x7f) }
+func (f FileEntry) IsBinary() bool { return f.Type() == ftBinary }
 func (f FileEntry) Name() Filename {
 	const hiAsciiSpace = 0xA0
 	size := 30
@@ -468,6 +639,8 @@ const lockBits uint8 = 0b1000_0000
 func (f FileEntry) lock()   { f[0x02] |= lockBits }
 func (f FileEntry) unlock() { f[0x02] &= ^lockBits }
 
+func (f FileEntry) setType(ft FileType) { f[0x02] = f[0x02]&lockBits | byte(ft) }
+
 func (f FileEntry) delete() byte {
 	prev20 := f[0x20]
 	f[0x20] = f[0x00]
@@ -564,6 +737,9 @@ const (
 	ftB              FileType = 0b0100_0000
 )
 
+// valid reports whether ft is one of the DOS file types.
+func (ft FileType) valid() bool { return ft&(ft-1) == 0 }
+
 func (ft FileType) String() string {
 	return map[FileType]string{
 		ftText:           "T",
@@ -577,6 +753,23 @@ func (ft FileType) String() string {
 	}[ft]
 }
 
+// ParseFileType returns the file type named by s: a letter as CATALOG shows
+// it, such as "B", or the type byte in hex, such as "$04". As the letters A and
+// B are each shown for two types, they mean Applesoft BASIC and BINARY.
+func ParseFileType(s string) (FileType, error) {
+	for _, ft := range []FileType{ftText, ftIntegerBasic, ftApplesoftBasic, ftBinary, ftS, ftRelocatable} {
+		if s == ft.String() {
+			return ft, nil
+		}
+	}
+	if hex, ok := strings.CutPrefix(s, "$"); ok {
+		if b, err := strconv.ParseUint(hex, 16, 8); err == nil && FileType(b).valid() && b < 0x80 {
+			return FileType(b), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is not a DOS file type", s)
+}
+
 /// Track Sector List Format
 /*
 http://fileformats.archiveteam.org/wiki/Apple_DOS_file_system#Track_Sector_List_Format
@@ -619,28 +812,13 @@ func (tsl tsList) DataSectorTS(offset uint) (uint, uint) {
 // DataSectors traverses the Track/Sector Lists and returns all sectors used by
 // file for data.
 func (dsk *Diskette) DataSectors(file FileEntry) (datas [][]byte) {
-	t, s := file.firstTSList()
-	fmt.Fprintf(os.Stderr, "\n\n%s - tsList track=%.2x sector=%.2x\n", file.Name().PathSafe(), t, s)
-
-	for t != 0 {
-		tsList := tsList(dsk.rawSector(t, s))
-		// fmt.Fprintf(os.Stderr, "%s - tsList track=%.2x sector=%.2x\n", file.Name().PathSafe(), t, s)
-
-		for _, offset := range tsList.DataSectorOffsets() {
-			dt, ds := tsList.DataSectorTS(offset)
-			fmt.Fprintf(os.Stderr, "%s -        track=%.2x sector=%.2x\n", file.Name().PathSafe(), dt, ds)
-			if dt == 0 {
-				// TODO: handle case of a non-sequential ("random") file that can have
-				// non-allocated data sectors. See "Beneath Apple DOS" Chapter 4.
-				break
-			}
-			dataSector := dsk.rawSector(dt, ds)
-			datas = append(datas, dataSector)
-		}
-
-		t, s = tsList.NextTSList()
+	r, err := dsk.Open(file)
+	if err != nil {
+		return nil
+	}
+	for i := range r.sectors {
+		datas = append(datas, r.sector(i))
 	}
-
 	return
 }
 
@@ -0,0 +1,483 @@
+package dsk
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+// testFiles are enough files to spread the catalog over two sectors.
+var testFiles = []dsktest.File{
+	{Name: "HELLO", Type: dsktest.ApplesoftBasic, Data: []byte{0x01, 0x00}},
+	{Name: "ONE", Type: dsktest.Text, Data: []byte("ONE\r")},
+	{Name: "TWO", Type: dsktest.Text, Data: []byte("TWO\r")},
+	{Name: "THREE", Type: dsktest.Text, Data: []byte("THREE\r")},
+	{Name: "FOUR", Type: dsktest.Text, Data: []byte("FOUR\r")},
+	{Name: "FIVE", Type: dsktest.Text, Data: []byte("FIVE\r")},
+	{Name: "SIX", Type: dsktest.Text, Data: []byte("SIX\r")},
+	{Name: "SEVEN", Type: dsktest.Text, Data: []byte("SEVEN\r")},
+	{Name: "PROG", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, []byte{0x60})},
+}
+
+func TestSectorOrderDetection(t *testing.T) {
+	dosImage := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	poImage := dsktest.ProDOSOrder(dosImage)
+
+	tests := []struct {
+		name  string
+		image []byte
+		order SectorOrder
+	}{
+		{"DISK.dsk", dosImage, DOSOrder},
+		{"DISK.do", dosImage, DOSOrder},
+		{"DISK.po", poImage, ProDOSOrder},
+		{"MISNAMED.dsk", poImage, ProDOSOrder},
+		{"MISNAMED.po", dosImage, DOSOrder},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dsk := loadImage(t, test.name, test.image)
+			if dsk.Order() != test.order {
+				t.Fatalf("Expected %s order, got %s", test.order, dsk.Order())
+			}
+			if names := catalogNames(dsk); !slices.Equal(names, fileNames(testFiles)) {
+				t.Fatal(fileNames(testFiles), "!=", names)
+			}
+		})
+	}
+}
+
+func TestProDOSOrderWritesInPlace(t *testing.T) {
+	dosImage := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	path := writeImage(t, "DISK.po", dsktest.ProDOSOrder(dosImage))
+
+	dsk, err := LoadDiskette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dsk.Lock(dsk.FindFile("PROG")); err != nil {
+		t.Fatal(err)
+	}
+
+	dsk, err = LoadDiskette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dsk.Order() != ProDOSOrder {
+		t.Fatal("Expected ProDOS order after saving, got", dsk.Order())
+	}
+	if !dsk.FindFile("PROG").IsLocked() {
+		t.Fatal("Expected PROG to be locked")
+	}
+}
+
+func TestGeometries(t *testing.T) {
+	tests := []struct {
+		name string
+		geo  dsktest.Geometry
+	}{
+		{"35 tracks, 13 sectors", dsktest.Geometry{Tracks: 35, Sectors: 13}},
+		{"35 tracks, 16 sectors", dsktest.Standard},
+		{"40 tracks, 16 sectors", dsktest.Geometry{Tracks: 40, Sectors: 16}},
+		{"50 tracks, 16 sectors", dsktest.Geometry{Tracks: 50, Sectors: 16}},
+		{"35 tracks, 32 sectors", dsktest.Geometry{Tracks: 35, Sectors: 32}},
+		{"50 tracks, 32 sectors", dsktest.Geometry{Tracks: 50, Sectors: 32}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Fill most of the disk, so the file reaches its last tracks.
+			sectors := (test.geo.Tracks - 18) * test.geo.Sectors * 9 / 10
+			big := make([]byte, sectors*dsktest.SectorSize)
+			for i := range big {
+				big[i] = byte(i%255 + 1)
+			}
+			image := dsktest.Image(test.geo, 254, dsktest.File{Name: "BIG", Type: dsktest.Text, Data: big})
+
+			dsk := loadImage(t, "DISK.dsk", image)
+			if int(dsk.NumTracks()) != test.geo.Tracks || int(dsk.SectorsPerTrack()) != test.geo.Sectors {
+				t.Fatalf("Expected %v, got %d tracks and %d sectors", test.geo, dsk.NumTracks(), dsk.SectorsPerTrack())
+			}
+			data, err := dsk.ReadAll(dsk.FindFile("BIG"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(big, data) {
+				t.Fatal("Expected contents of BIG to round-trip")
+			}
+			if !dsk.sectorFree(uint(test.geo.Tracks-1), uint(test.geo.Sectors-1)) {
+				t.Fatal("Expected last sector of the disk to be free")
+			}
+		})
+	}
+}
+
+func TestUnrecognizedImageIsAnError(t *testing.T) {
+	path := writeImage(t, "JUNK.dsk", make([]byte, 12345))
+	if _, err := LoadDiskette(path); err == nil {
+		t.Fatal("Expected an error loading a disk without a VTOC")
+	}
+}
+
+func TestWOZ(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		geo     dsktest.Geometry
+	}{
+		{"WOZ1 16 sectors", 1, dsktest.Standard},
+		{"WOZ2 16 sectors", 2, dsktest.Standard},
+		{"WOZ2 13 sectors", 2, dsktest.Geometry{Tracks: 35, Sectors: 13}},
+		{"WOZ2 40 tracks", 2, dsktest.Geometry{Tracks: 40, Sectors: 16}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			image := dsktest.Image(test.geo, 254, testFiles...)
+			dsk := loadImage(t, "DISK.woz", dsktest.WOZ(test.version, image, test.geo.Sectors))
+
+			if !slices.Equal(image, dsk.bytes) {
+				t.Fatal("Expected WOZ to decode to the original sectors")
+			}
+			if names := catalogNames(dsk); !slices.Equal(names, fileNames(testFiles)) {
+				t.Fatal(fileNames(testFiles), "!=", names)
+			}
+			if err := dsk.Lock(dsk.FindFile("PROG")); !errors.Is(err, os.ErrPermission) {
+				t.Fatal("Expected WOZ images to be read-only, got", err)
+			}
+		})
+	}
+}
+
+func TestCorruptWOZ(t *testing.T) {
+	woz := dsktest.WOZ(2, dsktest.Image(dsktest.Standard, 254), 16)
+	woz[len(woz)-1] ^= 0xFF
+	if _, err := LoadDiskette(writeImage(t, "DISK.woz", woz)); err == nil {
+		t.Fatal("Expected a CRC error")
+	}
+}
+
+func TestNIB(t *testing.T) {
+	for _, sectors := range []int{16, 13} {
+		geo := dsktest.Geometry{Tracks: 35, Sectors: sectors}
+		image := dsktest.Image(geo, 254, testFiles...)
+		nib := dsktest.NIB(image, sectors)
+		path := writeImage(t, "DISK.nib", nib)
+
+		dsk, err := LoadDiskette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(image, dsk.bytes) {
+			t.Fatal("Expected NIB to decode to the original sectors")
+		}
+		if err := dsk.Lock(dsk.FindFile("PROG")); err != nil {
+			t.Fatal(err)
+		}
+
+		saved, _ := os.ReadFile(path)
+		if len(saved) != len(nib) {
+			t.Fatalf("Expected NIB to stay %d bytes, got %d", len(nib), len(saved))
+		}
+		if !slices.Equal(nib[:17*6656], saved[:17*6656]) {
+			t.Fatal("Expected tracks before the catalog to be untouched")
+		}
+		if dsk, err = LoadDiskette(path); err != nil {
+			t.Fatal(err)
+		} else if !dsk.FindFile("PROG").IsLocked() {
+			t.Fatal("Expected PROG to be locked after reloading the NIB")
+		}
+	}
+}
+
+func TestEncodeNIB(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	for _, name := range []string{"DISK.dsk", "DISK.po"} {
+		source := image
+		if name == "DISK.po" {
+			source = dsktest.ProDOSOrder(image)
+		}
+		nib, err := loadImage(t, name, source).Encode(".nib")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(nib) != 232960 {
+			t.Fatal("Expected a 232960 byte NIB, got", len(nib))
+		}
+		if dsk := loadImage(t, "DISK.nib", nib); !slices.Equal(image, dsk.bytes) {
+			t.Fatalf("Expected NIB of %s to hold the original sectors", name)
+		}
+	}
+}
+
+func TestTwoIMG(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	tests := []struct {
+		name   string
+		format uint32
+		data   []byte
+		order  SectorOrder
+	}{
+		{"DOS order", 0, image, DOSOrder},
+		{"ProDOS order", 1, dsktest.ProDOSOrder(image), ProDOSOrder},
+		{"nibbles", 2, dsktest.NIB(image, 16), DOSOrder},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := dsktest.TwoIMG(test.format, test.data, false, "Hello, world!")
+			path := writeImage(t, "DISK.2mg", file)
+			dsk, err := LoadDiskette(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dsk.Order() != test.order {
+				t.Fatalf("Expected %v, got %v", test.order, dsk.Order())
+			}
+			if dsk.Comment() != "Hello, world!" {
+				t.Fatalf("Expected the comment, got %q", dsk.Comment())
+			}
+			if err := dsk.Lock(dsk.FindFile("PROG")); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, _ := os.ReadFile(path)
+			if len(saved) != len(file) {
+				t.Fatalf("Expected the 2MG to stay %d bytes, got %d", len(file), len(saved))
+			}
+			if !slices.Equal(file[:64], saved[:64]) {
+				t.Fatal("Expected the header to be preserved")
+			}
+			if trailer := 64 + len(test.data); !slices.Equal(file[trailer:], saved[trailer:]) {
+				t.Fatal("Expected the comment and creator data to be preserved")
+			}
+			if dsk, err = LoadDiskette(path); err != nil {
+				t.Fatal(err)
+			} else if !dsk.FindFile("PROG").IsLocked() {
+				t.Fatal("Expected PROG to be locked after reloading the 2MG")
+			}
+		})
+	}
+}
+
+func TestWriteProtectedTwoIMG(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	dsk := loadImage(t, "DISK.2mg", dsktest.TwoIMG(0, image, true, ""))
+	if err := dsk.Lock(dsk.FindFile("PROG")); !errors.Is(err, os.ErrPermission) {
+		t.Fatal("Expected a write-protected 2MG to be readonly, got", err)
+	}
+}
+
+func TestShrinkItDisk(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	for _, format := range []uint16{dsktest.Uncompressed, dsktest.LZW1, dsktest.LZW2} {
+		sdk := dsktest.ShrinkIt(format, dsktest.Record{Name: "DISK", Disk: dsktest.ProDOSOrder(image), Comment: "Side A"})
+		dsk := loadImage(t, "DISK.sdk", sdk)
+		if !slices.Equal(fileNames(testFiles), catalogNames(dsk)) {
+			t.Fatalf("format %d: expected %v, got %v", format, fileNames(testFiles), catalogNames(dsk))
+		}
+		if dsk.Comment() != "Side A" {
+			t.Fatalf("format %d: expected the record's comment, got %q", format, dsk.Comment())
+		}
+		if err := dsk.Lock(dsk.FindFile("PROG")); !errors.Is(err, os.ErrPermission) {
+			t.Fatalf("format %d: expected SDK to be readonly, got %v", format, err)
+		}
+	}
+}
+
+func TestZip(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	path := writeZip(t, map[string][]byte{
+		"ONE.dsk":        image,
+		"disks/TWO.po":   dsktest.ProDOSOrder(image),
+		"README.txt":     []byte("not a disk"),
+		"disks/THREE.do": image,
+		"disks/ONE.dsk":  image,
+		"PRODOS.po":      make([]byte, len(image)),
+	})
+	original, _ := os.ReadFile(path)
+
+	disks, skipped, err := LoadZip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || !strings.Contains(skipped[0].Error(), "PRODOS.po") {
+		t.Fatal("Expected only PRODOS.po to be skipped, got", skipped)
+	}
+	var names []string
+	for _, dsk := range disks {
+		names = append(names, dsk.Name())
+		if !slices.Equal(fileNames(testFiles), catalogNames(dsk)) {
+			t.Fatalf("%s: expected %v, got %v", dsk.Name(), fileNames(testFiles), catalogNames(dsk))
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"ONE", "THREE", "TWO", "disks!ONE"}) {
+		t.Fatal("Expected the four disk images, with unique names, got", names)
+	}
+
+	err = disks[0].Lock(disks[0].FindFile("PROG"))
+	if !errors.Is(err, os.ErrPermission) || !strings.Contains(err.Error(), "overlay") {
+		t.Fatal("Expected an error suggesting an overlay, got", err)
+	}
+
+	overlays := t.TempDir()
+	if disks, _, err = LoadZipOverlay(path, overlays); err != nil {
+		t.Fatal(err)
+	}
+	for _, dsk := range disks {
+		if err := dsk.Lock(dsk.FindFile("PROG")); err != nil {
+			t.Fatal(err)
+		}
+		if err := dsk.Commit(); !errors.Is(err, os.ErrPermission) {
+			t.Fatal("Expected commit into a zip to be refused, got", err)
+		}
+	}
+	if current, _ := os.ReadFile(path); !slices.Equal(original, current) {
+		t.Fatal("Expected the zip to be untouched")
+	}
+	if disks, _, err = LoadZipOverlay(path, overlays); err != nil {
+		t.Fatal(err)
+	}
+	for _, dsk := range disks {
+		if !dsk.FindFile("PROG").IsLocked() {
+			t.Fatalf("%s: expected the overlay to be applied", dsk.Name())
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+	dsk := loadImage(t, "DISK.dsk", image)
+	for _, ext := range []string{".dsk", ".po", ".2mg"} {
+		encoded, err := dsk.Encode(ext)
+		if err != nil {
+			t.Fatal(ext, err)
+		}
+		if got := loadImage(t, "DISK"+ext, encoded); !slices.Equal(image, got.sectorsInOrder(DOSOrder)) {
+			t.Fatalf("Expected the %s image to hold the original sectors", ext)
+		}
+	}
+	if po, _ := dsk.Encode(".po"); !slices.Equal(po, dsktest.ProDOSOrder(image)) {
+		t.Fatal("Expected a ProDOS-ordered .po image")
+	}
+}
+
+// writeImage saves image to a temporary file and returns its path.
+func writeImage(t *testing.T, name string, image []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeZip saves a zip archive of files to a temporary file and returns its path.
+func writeZip(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writeImage(t, "DISKS.zip", buf.Bytes())
+}
+
+// loadImage loads image as if from a file called name.
+func loadImage(t *testing.T, name string, image []byte) *Diskette {
+	t.Helper()
+	dsk, err := LoadDiskette(writeImage(t, name, image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dsk
+}
+
+func catalogNames(dsk *Diskette) (names []string) {
+	for _, entry := range dsk.Catalog() {
+		names = append(names, entry.Name().String())
+	}
+	return
+}
+
+func fileNames(files []dsktest.File) (names []string) {
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return
+}
+
+func TestParseFileType(t *testing.T) {
+	for s, want := range map[string]FileType{"T": ftText, "A": ftApplesoftBasic, "B": ftBinary, "$20": ftA, "$40": ftB} {
+		if ft, err := ParseFileType(s); err != nil || ft != want {
+			t.Errorf("ParseFileType(%q) = %v, %v; want %v", s, ft, err, want)
+		}
+	}
+	for _, s := range []string{"", "Q", "b", "$03", "$80", "$100"} {
+		if _, err := ParseFileType(s); err == nil {
+			t.Errorf("Expected %q to be refused", s)
+		}
+	}
+}
+
+func TestFreeSectors(t *testing.T) {
+	files := []dsktest.File{{Name: "HELLO", Type: dsktest.Text, Data: make([]byte, 300)}}
+	dsk, err := decodeDiskette("FREE.DSK", dsktest.Image(dsktest.Standard, 254, files...), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tracks 3-34 are free, except the catalog track and HELLO's 3 sectors.
+	if free := dsk.FreeSectors(); free != 31*16-3 {
+		t.Errorf("FreeSectors() = %d, want %d", free, 31*16-3)
+	}
+}
+
+func TestDamagedCatalog(t *testing.T) {
+	// link points the catalog sector at track 17, sector s to another.
+	link := func(s int, next [2]byte) []byte {
+		image := dsktest.Image(dsktest.Standard, 254, testFiles...)
+		copy(image[(17*16+s)*dsktest.SectorSize+0x01:], next[:])
+		return image
+	}
+	db, err := ParseHashDBTSV(strings.NewReader("name\tmd5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, _ := ParseQuery("HELLO")
+
+	for _, test := range []struct {
+		name  string
+		image []byte
+		files int // before the damage
+	}{
+		{"OFF.dsk", link(15, [2]byte{40, 0}), 7},
+		{"LOOP.dsk", link(14, [2]byte{17, 15}), len(testFiles)},
+	} {
+		dsk := loadImage(t, test.name, test.image)
+		entries, err := dsk.ReadCatalog()
+		if !errors.Is(err, errCorruptCatalog) || len(entries) != test.files {
+			t.Errorf("%s: Expected %d files and a corrupt catalog, got %d, %v", test.name, test.files, len(entries), err)
+		}
+		if hits, err := Search(dsk, q); len(hits) == 0 || !errors.Is(err, errCorruptCatalog) {
+			t.Errorf("%s: Expected HELLO to be found and the damage reported, got %v, %v", test.name, hits, err)
+		}
+		if diff := Diff(dsk, dsk); !strings.Contains(diff, "  ! "+err.Error()) {
+			t.Errorf("%s: Expected the diff to report the damage, got\n%s", test.name, diff)
+		}
+		if _, err := db.Identify(dsk); !errors.Is(err, errCorruptCatalog) {
+			t.Errorf("%s: Expected the damage to be reported, got %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package dsktest synthesizes Apple DOS 3.3 disk images for use in tests.
+package dsktest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Geometry describes the physical layout of a disk image.
+type Geometry struct {
+	Tracks  int
+	Sectors int // per track
+}
+
+// Standard is the geometry of a 140K, 16-sector, 35-track floppy.
+var Standard = Geometry{Tracks: 35, Sectors: 16}
+
+// SectorSize is the number of bytes in every sector.
+const SectorSize = 256
+
+// File types as stored in a catalog entry.
+const (
+	Text           byte = 0x00
+	IntegerBasic   byte = 0x01
+	ApplesoftBasic byte = 0x02
+	Binary         byte = 0x04
+)
+
+// File is a file to be placed on a synthesized disk.
+type File struct {
+	Name    string // normal (Hi-ASCII) name, ignored if RawName is set
+	RawName []byte // exact bytes of the name, for inverse or control characters
+	Type    byte
+	Locked  bool
+	Deleted bool
+	Data    []byte // contents as stored on disk, including any binary header
+}
+
+// BinaryData prefixes data with the address and length header used by DOS
+// for BINARY files.
+func BinaryData(address uint16, data []byte) []byte {
+	header := []byte{byte(address), byte(address >> 8), byte(len(data)), byte(len(data) >> 8)}
+	return append(header, data...)
+}
+
+// Image returns a DOS-ordered disk image with the given geometry and volume
+// number containing files, in catalog order.
+func Image(geo Geometry, volume byte, files ...File) []byte {
+	img := &image{geo: geo, bytes: make([]byte, geo.Tracks*geo.Sectors*SectorSize)}
+	img.format(volume)
+	for _, file := range files {
+		img.add(file)
+	}
+	return img.bytes
+}
+
+// Write saves a standard disk image containing files to dir/name and returns
+// its path.
+func Write(t testing.TB, dir, name string, files ...File) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, Image(Standard, 254, files...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const vtocTrack = 17
+
+type image struct {
+	geo   Geometry
+	bytes []byte
+	next  int // next free track for file data
+	entry int // next free catalog entry
+}
+
+func (img *image) sector(track, sector int) []byte {
+	offset := (track*img.geo.Sectors + sector) * SectorSize
+	return img.bytes[offset : offset+SectorSize]
+}
+
+func (img *image) vtoc() []byte { return img.sector(vtocTrack, 0) }
+
+func (img *image) format(volume byte) {
+	vtoc := img.vtoc()
+	vtoc[0x01] = vtocTrack
+	vtoc[0x02] = byte(img.geo.Sectors - 1)
+	vtoc[0x03] = 3
+	vtoc[0x06] = volume
+	vtoc[0x27] = 122
+	vtoc[0x30] = vtocTrack
+	vtoc[0x31] = 1
+	vtoc[0x34] = byte(img.geo.Tracks)
+	vtoc[0x35] = byte(img.geo.Sectors)
+	vtoc[0x36] = SectorSize & 0xff
+	vtoc[0x37] = SectorSize >> 8
+
+	// Tracks 0-2 hold DOS and the catalog track is always in use.
+	for t := 3; t < img.geo.Tracks; t++ {
+		if t == vtocTrack {
+			continue
+		}
+		for s := 0; s < img.geo.Sectors; s++ {
+			img.setFree(t, s, true)
+		}
+	}
+
+	for s := img.geo.Sectors - 1; s > 0; s-- {
+		catalog := img.sector(vtocTrack, s)
+		if s > 1 {
+			catalog[0x01] = vtocTrack
+			catalog[0x02] = byte(s - 1)
+		}
+	}
+
+	img.next = vtocTrack + 1
+}
+
+// setFree marks a sector in the VTOC free-sector bitmap.
+//
+// The four bytes for each track form a big-endian bitmap in which the highest
+// sector is the most significant bit of the first byte.
+func (img *image) setFree(track, sector int, free bool) {
+	bits := 16
+	if img.geo.Sectors > 16 {
+		bits = 32
+	}
+	mask := uint32(1) << (sector + 32 - bits)
+	row := img.vtoc()[0x38+4*track:][:4]
+	value := uint32(row[0])<<24 | uint32(row[1])<<16 | uint32(row[2])<<8 | uint32(row[3])
+	if free {
+		value |= mask
+	} else {
+		value &^= mask
+	}
+	row[0], row[1], row[2], row[3] = byte(value>>24), byte(value>>16), byte(value>>8), byte(value)
+}
+
+// alloc returns the next free sector, searching upward from the catalog.
+func (img *image) alloc() (int, int) {
+	for {
+		if img.next >= img.geo.Tracks {
+			panic("dsktest: disk full")
+		}
+		for s := img.geo.Sectors - 1; s >= 0; s-- {
+			row := img.vtoc()[0x38+4*img.next:][:4]
+			before := string(row)
+			img.setFree(img.next, s, false)
+			if string(row) != before {
+				return img.next, s
+			}
+		}
+		img.next++
+	}
+}
+
+func (img *image) add(file File) {
+	const pairsPerList = 122
+
+	sectors := (len(file.Data) + SectorSize - 1) / SectorSize
+	if sectors == 0 {
+		sectors = 1
+	}
+
+	var (
+		used      = 0
+		firstT    = 0
+		firstS    = 0
+		list      []byte
+		pair      = pairsPerList
+		listIndex = 0
+	)
+	for i := 0; i < sectors; i++ {
+		if pair == pairsPerList {
+			t, s := img.alloc()
+			used++
+			if list == nil {
+				firstT, firstS = t, s
+			} else {
+				list[0x01], list[0x02] = byte(t), byte(s)
+			}
+			list = img.sector(t, s)
+			offset := listIndex * pairsPerList
+			list[0x05], list[0x06] = byte(offset), byte(offset>>8)
+			listIndex++
+			pair = 0
+		}
+		t, s := img.alloc()
+		used++
+		list[0x0C+2*pair], list[0x0D+2*pair] = byte(t), byte(s)
+		pair++
+
+		start := i * SectorSize
+		end := min(start+SectorSize, len(file.Data))
+		if start < end {
+			copy(img.sector(t, s), file.Data[start:end])
+		}
+	}
+
+	entry := img.catalogEntry()
+	name := file.RawName
+	if name == nil {
+		for _, ch := range []byte(file.Name) {
+			name = append(name, ch|0x80)
+		}
+	}
+	for i := 0; i < 30; i++ {
+		entry[0x03+i] = 0xA0
+	}
+	copy(entry[0x03:][:30], name)
+	entry[0x00] = byte(firstT)
+	entry[0x01] = byte(firstS)
+	entry[0x02] = file.Type
+	if file.Locked {
+		entry[0x02] |= 0x80
+	}
+	entry[0x21], entry[0x22] = byte(used), byte(used>>8)
+	if file.Deleted {
+		entry[0x20] = entry[0x00]
+		entry[0x00] = 0xFF
+	}
+}
+
+// catalogEntry returns the next unused 35-byte catalog entry.
+func (img *image) catalogEntry() []byte {
+	const perSector = 7
+	sector := img.geo.Sectors - 1 - img.entry/perSector
+	if sector < 1 {
+		panic("dsktest: catalog full")
+	}
+	offset := 0x0B + 0x23*(img.entry%perSector)
+	img.entry++
+	return img.sector(vtocTrack, sector)[offset:][:0x23]
+}
+
+// ProDOSOrder returns a copy of the DOS-ordered, 16-sector image img with the
+// sectors of each track rearranged into ProDOS order, as found in .po files.
+func ProDOSOrder(img []byte) []byte {
+	positions := [16]int{0x0, 0xE, 0xD, 0xC, 0xB, 0xA, 0x9, 0x8, 0x7, 0x6, 0x5, 0x4, 0x3, 0x2, 0x1, 0xF}
+	const trackSize = 16 * SectorSize
+
+	out := make([]byte, len(img))
+	for track := 0; track*trackSize < len(img); track++ {
+		for sector, position := range positions {
+			src := img[track*trackSize+sector*SectorSize:][:SectorSize]
+			copy(out[track*trackSize+position*SectorSize:], src)
+		}
+	}
+	return out
+}
@@ -0,0 +1,265 @@
+package dsktest
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var diskBytes62 = [64]byte{
+	0x96, 0x97, 0x9A, 0x9B, 0x9D, 0x9E, 0x9F, 0xA6,
+	0xA7, 0xAB, 0xAC, 0xAD, 0xAE, 0xAF, 0xB2, 0xB3,
+	0xB4, 0xB5, 0xB6, 0xB7, 0xB9, 0xBA, 0xBB, 0xBC,
+	0xBD, 0xBE, 0xBF, 0xCB, 0xCD, 0xCE, 0xCF, 0xD3,
+	0xD6, 0xD7, 0xD9, 0xDA, 0xDB, 0xDC, 0xDD, 0xDE,
+	0xDF, 0xE5, 0xE6, 0xE7, 0xE9, 0xEA, 0xEB, 0xEC,
+	0xED, 0xEE, 0xEF, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6,
+	0xF7, 0xF9, 0xFA, 0xFB, 0xFC, 0xFD, 0xFE, 0xFF,
+}
+
+var diskBytes53 = [32]byte{
+	0xAB, 0xAD, 0xAE, 0xAF, 0xB5, 0xB6, 0xB7, 0xBA,
+	0xBB, 0xBD, 0xBE, 0xBF, 0xD6, 0xD7, 0xDA, 0xDB,
+	0xDD, 0xDE, 0xDF, 0xEA, 0xEB, 0xED, 0xEE, 0xEF,
+	0xF5, 0xF6, 0xF7, 0xFA, 0xFB, 0xFD, 0xFE, 0xFF,
+}
+
+// dos33Physical maps DOS 3.3 logical sectors to physical sectors.
+var dos33Physical = [16]int{0x0, 0xD, 0xB, 0x9, 0x7, 0x5, 0x3, 0x1, 0xE, 0xC, 0xA, 0x8, 0x6, 0x4, 0x2, 0xF}
+
+func encode44(value byte) []byte { return []byte{value>>1 | 0xAA, value | 0xAA} }
+
+// encode62 returns the 343 disk bytes of a "6 and 2" data field, with checksum.
+func encode62(data []byte) []byte {
+	swap := func(b byte) byte { return (b&1)<<1 | (b&2)>>1 }
+	values := make([]byte, 0, 342)
+	for i := 0; i < 86; i++ {
+		v := swap(data[i] & 3)
+		if i+86 < 256 {
+			v |= swap(data[i+86]&3) << 2
+		}
+		if i+172 < 256 {
+			v |= swap(data[i+172]&3) << 4
+		}
+		values = append(values, v)
+	}
+	for _, b := range data {
+		values = append(values, b>>2)
+	}
+	return chain(values, diskBytes62[:])
+}
+
+// encode53 returns the 411 disk bytes of a "5 and 3" data field, with checksum.
+func encode53(data []byte) []byte {
+	const chunk = 51
+	var low [154]byte
+	var high [256]byte
+	for i := 0; i < chunk; i++ {
+		d := data[5*(chunk-1-i):]
+		high[i], high[chunk+i], high[2*chunk+i], high[3*chunk+i], high[4*chunk+i] =
+			d[0]>>3, d[1]>>3, d[2]>>3, d[3]>>3, d[4]>>3
+		low[i] = (d[0]&7)<<2 | (d[3]>>2&1)<<1 | d[4]>>2&1
+		low[chunk+i] = (d[1]&7)<<2 | (d[3]>>1&1)<<1 | d[4]>>1&1
+		low[2*chunk+i] = (d[2]&7)<<2 | (d[3]&1)<<1 | d[4]&1
+	}
+	high[255] = data[255] >> 3
+	low[153] = data[255] & 7
+
+	values := make([]byte, 0, 410)
+	for i := len(low) - 1; i >= 0; i-- {
+		values = append(values, low[i])
+	}
+	values = append(values, high[:]...)
+	return chain(values, diskBytes53[:])
+}
+
+// chain XORs each value with the previous one, translates them to disk bytes
+// and appends the checksum.
+func chain(values, diskBytes []byte) []byte {
+	out := make([]byte, 0, len(values)+1)
+	prev := byte(0)
+	for _, v := range values {
+		out = append(out, diskBytes[v^prev])
+		prev = v
+	}
+	return append(out, diskBytes[prev])
+}
+
+// trackNibbles returns the disk bytes of one track of a DOS-ordered image with
+// sectorsPerTrack sectors of 16 ("6 and 2") or 13 ("5 and 3").
+func trackNibbles(img []byte, volume byte, track, sectorsPerTrack int) []byte {
+	addressMark, encode := byte(0x96), encode62
+	if sectorsPerTrack == 13 {
+		addressMark, encode = 0xB5, encode53
+	}
+
+	position := make([]int, sectorsPerTrack)
+	for s := range position {
+		position[s] = s
+	}
+	if sectorsPerTrack == 16 {
+		for s, physical := range dos33Physical {
+			position[physical] = s
+		}
+	}
+
+	sync := func(nibbles []byte, n int) []byte {
+		for ; n > 0; n-- {
+			nibbles = append(nibbles, 0xFF)
+		}
+		return nibbles
+	}
+
+	nibbles := sync(nil, 48)
+	for physical := 0; physical < sectorsPerTrack; physical++ {
+		t, s := byte(track), byte(physical)
+		nibbles = append(nibbles, 0xD5, 0xAA, addressMark)
+		nibbles = append(nibbles, encode44(volume)...)
+		nibbles = append(nibbles, encode44(t)...)
+		nibbles = append(nibbles, encode44(s)...)
+		nibbles = append(nibbles, encode44(volume^t^s)...)
+		nibbles = append(nibbles, 0xDE, 0xAA, 0xEB)
+		nibbles = sync(nibbles, 6)
+		nibbles = append(nibbles, 0xD5, 0xAA, 0xAD)
+		offset := (track*sectorsPerTrack + position[physical]) * SectorSize
+		nibbles = append(nibbles, encode(img[offset:][:SectorSize])...)
+		nibbles = append(nibbles, 0xDE, 0xAA, 0xEB)
+		nibbles = sync(nibbles, 20)
+	}
+	return nibbles
+}
+
+// trackBits returns the bitstream of one track, as in trackNibbles, and the
+// number of bits in it. Every $FF is followed by two zero bits, which makes the
+// gaps self-syncing.
+func trackBits(img []byte, volume byte, track, sectorsPerTrack int) ([]byte, int) {
+	var bits []byte
+	count := 0
+	bit := func(b byte) {
+		if count%8 == 0 {
+			bits = append(bits, 0)
+		}
+		bits[len(bits)-1] |= b << (7 - count%8)
+		count++
+	}
+	for _, nibble := range trackNibbles(img, volume, track, sectorsPerTrack) {
+		for i := 7; i >= 0; i-- {
+			bit(nibble >> i & 1)
+		}
+		if nibble == 0xFF {
+			bit(0)
+			bit(0)
+		}
+	}
+	return bits, count
+}
+
+// NIB returns a .nib file holding the tracks of a DOS-ordered image with 13 or
+// 16 sectors per track.
+func NIB(img []byte, sectorsPerTrack int) []byte {
+	const trackSize = 6656
+	tracks := len(img) / (sectorsPerTrack * SectorSize)
+	nib := make([]byte, 0, tracks*trackSize)
+	for t := 0; t < tracks; t++ {
+		track := make([]byte, trackSize)
+		for i := range track {
+			track[i] = 0xFF
+		}
+		copy(track, trackNibbles(img, 254, t, sectorsPerTrack))
+		nib = append(nib, track...)
+	}
+	return nib
+}
+
+// WOZ returns a WOZ file of the given version (1 or 2) holding the tracks of
+// a DOS-ordered image with 13 or 16 sectors per track.
+func WOZ(version int, img []byte, sectorsPerTrack int) []byte {
+	const (
+		v1TrackSize = 6656
+		blockSize   = 512
+	)
+	tracks := len(img) / (sectorsPerTrack * SectorSize)
+
+	chunk := func(id string, data []byte) []byte {
+		out := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(data)))
+		return append(out, data...)
+	}
+
+	info := make([]byte, 60)
+	info[0] = byte(version)
+	info[1] = 1 // 5.25"
+	copy(info[5:37], "dsktest                         ")
+
+	tmap := make([]byte, 160)
+	for i := range tmap {
+		tmap[i] = 0xFF
+	}
+	for t := 0; t < tracks; t++ {
+		tmap[4*t] = byte(t)
+	}
+
+	var trks []byte
+	if version == 1 {
+		for t := 0; t < tracks; t++ {
+			bits, count := trackBits(img, 254, t, sectorsPerTrack)
+			entry := make([]byte, v1TrackSize)
+			copy(entry, bits)
+			binary.LittleEndian.PutUint16(entry[6646:], uint16(len(bits)))
+			binary.LittleEndian.PutUint16(entry[6648:], uint16(count))
+			trks = append(trks, entry...)
+		}
+	} else {
+		// Header, INFO and TMAP chunks and the TRK entries fill the first 3 blocks.
+		const firstBlock = 3
+		entries := make([]byte, 160*8)
+		var data []byte
+		for t := 0; t < tracks; t++ {
+			bits, count := trackBits(img, 254, t, sectorsPerTrack)
+			blocks := (len(bits) + blockSize - 1) / blockSize
+			binary.LittleEndian.PutUint16(entries[8*t:], uint16(firstBlock+len(data)/blockSize))
+			binary.LittleEndian.PutUint16(entries[8*t+2:], uint16(blocks))
+			binary.LittleEndian.PutUint32(entries[8*t+4:], uint32(count))
+			padded := make([]byte, blocks*blockSize)
+			copy(padded, bits)
+			data = append(data, padded...)
+		}
+		trks = append(entries, data...)
+	}
+
+	body := chunk("INFO", info)
+	body = append(body, chunk("TMAP", tmap)...)
+	body = append(body, chunk("TRKS", trks)...)
+
+	header := []byte{'W', 'O', 'Z', '0' + byte(version), 0xFF, 0x0A, 0x0D, 0x0A}
+	header = binary.LittleEndian.AppendUint32(header, crc32.ChecksumIEEE(body))
+	return append(header, body...)
+}
+
+// TwoIMG returns a .2mg file wrapping data, which is in the given format
+// (0 = DOS order, 1 = ProDOS order, 2 = nibbles), with a comment and some
+// creator data after it.
+func TwoIMG(format uint32, data []byte, locked bool, comment string) []byte {
+	const headerSize = 64
+	creator := []byte("dsktest")
+
+	header := make([]byte, headerSize)
+	copy(header, "2IMGdskt")
+	binary.LittleEndian.PutUint16(header[0x08:], headerSize)
+	binary.LittleEndian.PutUint16(header[0x0A:], 1)
+	binary.LittleEndian.PutUint32(header[0x0C:], format)
+	if locked {
+		binary.LittleEndian.PutUint32(header[0x10:], 1<<31)
+	}
+	if format == 1 {
+		binary.LittleEndian.PutUint32(header[0x14:], uint32(len(data)/512))
+	}
+	binary.LittleEndian.PutUint32(header[0x18:], headerSize)
+	binary.LittleEndian.PutUint32(header[0x1C:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[0x20:], uint32(headerSize+len(data)))
+	binary.LittleEndian.PutUint32(header[0x24:], uint32(len(comment)))
+	binary.LittleEndian.PutUint32(header[0x28:], uint32(headerSize+len(data)+len(comment)))
+	binary.LittleEndian.PutUint32(header[0x2C:], uint32(len(creator)))
+
+	file := append(header, data...)
+	file = append(file, comment...)
+	return append(file, creator...)
+}
@@ -0,0 +1,255 @@
+package dsktest
+
+import (
+	"encoding/binary"
+)
+
+// NuFX thread formats.
+const (
+	Uncompressed = 0
+	LZW1         = 2
+	LZW2         = 3
+)
+
+// Record is a file or disk image to be placed in a synthesized NuFX archive.
+type Record struct {
+	Name     string // with '/' between the parts of the path
+	FileType byte   // ProDOS file type
+	AuxType  uint16
+	Data     []byte // data fork
+	Resource []byte // resource fork, if any
+	Disk     []byte // a ProDOS-ordered 140K disk image, instead of any forks
+	Comment  string
+}
+
+// ShrinkIt returns a NuFX archive holding records, with every data thread
+// stored in format.
+func ShrinkIt(format uint16, records ...Record) []byte {
+	master := make([]byte, 48)
+	copy(master, "\x4E\xF5\x46\xE9\x6C\xE5")
+	binary.LittleEndian.PutUint32(master[0x08:], uint32(len(records)))
+	copy(master[0x0C:], []byte{0, 0, 12, 90, 0, 0})  // 1990-01-01 12:00:00
+	copy(master[0x14:], []byte{0, 0, 12, 124, 4, 9}) // 2024-10-05 12:00:00
+	binary.LittleEndian.PutUint16(master[0x1C:], 2)
+
+	archive := master
+	for _, record := range records {
+		archive = append(archive, shrinkRecord(format, record)...)
+	}
+	binary.LittleEndian.PutUint32(archive[0x26:], uint32(len(archive)))
+	binary.LittleEndian.PutUint16(archive[0x06:], crc16(archive[8:48]))
+	return archive
+}
+
+func shrinkRecord(format uint16, record Record) []byte {
+	const attribCount = 58
+
+	type thread struct {
+		class, format, kind uint16
+		size                int
+		data                []byte
+	}
+	threads := []thread{{class: 3, size: len(record.Name), data: []byte(record.Name)}}
+	if record.Comment != "" {
+		threads = append(threads, thread{class: 0, kind: 1, size: len(record.Comment), data: []byte(record.Comment)})
+	}
+	add := func(kind uint16, data []byte) {
+		threads = append(threads, thread{class: 2, format: format, kind: kind, size: len(data), data: compress(format, data)})
+	}
+
+	header := make([]byte, attribCount+2)
+	copy(header, "\x4E\xF5\x46\xD8")
+	binary.LittleEndian.PutUint16(header[0x06:], attribCount)
+	binary.LittleEndian.PutUint16(header[0x08:], 3)
+	binary.LittleEndian.PutUint16(header[0x0E:], 1) // ProDOS
+	binary.LittleEndian.PutUint16(header[0x10:], '/')
+	binary.LittleEndian.PutUint32(header[0x12:], 0xE3)
+	copy(header[0x28:], []byte{30, 15, 9, 87, 13, 5}) // 1987-06-14 09:15:30
+	if record.Disk != nil {
+		binary.LittleEndian.PutUint32(header[0x1A:], uint32(len(record.Disk)/512))
+		binary.LittleEndian.PutUint16(header[0x1E:], 512)
+		add(1, record.Disk)
+	} else {
+		binary.LittleEndian.PutUint32(header[0x16:], uint32(record.FileType))
+		binary.LittleEndian.PutUint32(header[0x1A:], uint32(record.AuxType))
+		binary.LittleEndian.PutUint16(header[0x1E:], 1) // seedling
+		add(0, record.Data)
+		if record.Resource != nil {
+			binary.LittleEndian.PutUint16(header[0x1E:], 5) // extended
+			add(2, record.Resource)
+		}
+	}
+	binary.LittleEndian.PutUint32(header[0x0A:], uint32(len(threads)))
+
+	var data []byte
+	for _, t := range threads {
+		th := make([]byte, 16)
+		binary.LittleEndian.PutUint16(th[0x00:], t.class)
+		binary.LittleEndian.PutUint16(th[0x02:], t.format)
+		binary.LittleEndian.PutUint16(th[0x04:], t.kind)
+		binary.LittleEndian.PutUint32(th[0x08:], uint32(t.size))
+		binary.LittleEndian.PutUint32(th[0x0C:], uint32(len(t.data)))
+		header = append(header, th...)
+		data = append(data, t.data...)
+	}
+	binary.LittleEndian.PutUint16(header[0x04:], crc16(header[6:]))
+	return append(header, data...)
+}
+
+// compress returns data as stored in a thread of the given format.
+func compress(format uint16, data []byte) []byte {
+	const (
+		chunkSize = 4096
+		delimiter = 0xDB
+	)
+	if format == Uncompressed {
+		return data
+	}
+
+	padded := make([]byte, (len(data)+chunkSize-1)/chunkSize*chunkSize)
+	copy(padded, data)
+
+	var out []byte
+	if format == LZW1 {
+		out = binary.LittleEndian.AppendUint16(out, crc16(padded))
+	}
+	out = append(out, 0, delimiter)
+
+	lzw := &lzwEncoder{}
+	for offset := 0; offset < len(padded); offset += chunkSize {
+		chunk := rle(padded[offset:][:chunkSize], delimiter)
+		if len(chunk) >= chunkSize {
+			chunk = padded[offset:][:chunkSize]
+		}
+
+		if format == LZW1 {
+			lzw.reset()
+		}
+		compressed := lzw.encode(chunk)
+		useLZW := len(compressed) < len(chunk)
+		if !useLZW {
+			lzw.reset() // as the decoder does after a chunk that is not LZW compressed
+		}
+
+		switch {
+		case format == LZW1 && useLZW:
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(chunk)))
+			out = append(out, 1)
+			out = append(out, compressed...)
+		case format == LZW1:
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(chunk)))
+			out = append(out, 0)
+			out = append(out, chunk...)
+		case useLZW:
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(chunk))|0x8000)
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(compressed)+4))
+			out = append(out, compressed...)
+		default:
+			out = binary.LittleEndian.AppendUint16(out, uint16(len(chunk)))
+			out = append(out, chunk...)
+		}
+	}
+	return out
+}
+
+// rle run-length encodes data: runs of four or more bytes, and every
+// delimiter, become the delimiter, the byte and the length minus one.
+func rle(data []byte, delimiter byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := 1
+		for i+n < len(data) && n < 256 && data[i+n] == data[i] {
+			n++
+		}
+		if n >= 4 || data[i] == delimiter {
+			out = append(out, delimiter, data[i], byte(n-1))
+		} else {
+			for j := 0; j < n; j++ {
+				out = append(out, data[i])
+			}
+		}
+		i += n
+	}
+	return out
+}
+
+// lzwEncoder compresses chunks of an LZW/1 or LZW/2 thread.
+type lzwEncoder struct {
+	codes map[[2]int]int // prefix code and byte to code
+	next  int
+	prev  int // the last code written, or -1
+}
+
+func (e *lzwEncoder) reset() {
+	e.codes, e.next, e.prev = make(map[[2]int]int), 0x101, -1
+}
+
+// encode compresses data, continuing from the table left by any previous
+// chunk as LZW/2 does.
+func (e *lzwEncoder) encode(data []byte) []byte {
+	if e.codes == nil {
+		e.reset()
+	}
+
+	var out []byte
+	bits := 0
+	write := func(code int) {
+		width := 12
+		switch {
+		case e.next < 0x200:
+			width = 9
+		case e.next < 0x400:
+			width = 10
+		case e.next < 0x800:
+			width = 11
+		}
+		for i := 0; i < width; i++ {
+			if bits%8 == 0 {
+				out = append(out, 0)
+			}
+			out[len(out)-1] |= byte(code>>i&1) << (bits % 8)
+			bits++
+		}
+	}
+	define := func(prefix int, b byte) {
+		if e.next < 0x1000 {
+			e.codes[[2]int{prefix, int(b)}] = e.next
+			e.next++
+		}
+	}
+
+	// The decoder defines an entry with each code after the first, even
+	// across chunks.
+	if e.prev >= 0 {
+		define(e.prev, data[0])
+	}
+	w := int(data[0])
+	for _, b := range data[1:] {
+		if code, ok := e.codes[[2]int{w, int(b)}]; ok {
+			w = code
+			continue
+		}
+		write(w)
+		define(w, b)
+		w = int(b)
+	}
+	write(w)
+	e.prev = w
+	return out
+}
+
+// crc16 is the CCITT CRC-16 used by XMODEM and NuFX.
+func crc16(data []byte) uint16 {
+	crc := uint16(0)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,110 @@
+package dsk
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+/// Image Formats
+/*
+Disk images come in several formats. Sector images (.dsk, .do, .po) hold the
+sectors themselves, while others wrap them in a header, compress them, or store
+the nibbles or bits that were read from the floppy's surface.
+
+Each format decodes the file on the host into a sector image, which is what
+the Diskette works with. Formats that can also encode a sector image back into
+the host's format are writable; the rest are served read-only.
+*/
+
+// imageDecoder extracts a sector image from a disk image file.
+type imageDecoder interface {
+	// decode returns the sectors stored in file, all tracks one after another.
+	decode(file []byte) ([]byte, error)
+}
+
+// imageEncoder converts a sector image back into a disk image file.
+type imageEncoder interface {
+	// encode returns the disk image file holding sectors.
+	encode(sectors []byte) ([]byte, error)
+}
+
+// describedImage is implemented by formats whose files have a header that
+// describes the image they hold.
+type describedImage interface {
+	info() imageInfo
+}
+
+// imageInfo is what a header says about the image it describes.
+type imageInfo struct {
+	order   SectorOrder
+	locked  bool // write protected
+	comment string
+}
+
+// formatByExt returns a decoder for the format implied by the extension of path.
+func formatByExt(path string) imageDecoder {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".woz":
+		return wozImage{}
+	case ".nib":
+		return &nibImage{}
+	case ".2mg", ".2img":
+		return &twoIMGImage{}
+	case ".sdk":
+		return &shrinkItImage{}
+	default:
+		return sectorImage{}
+	}
+}
+
+// isDiskImage reports whether the extension of path is that of a disk image.
+func isDiskImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dsk", ".do", ".po", ".woz", ".nib", ".2mg", ".2img", ".sdk":
+		return true
+	}
+	return false
+}
+
+// sectorImage is a plain sector image, such as a .dsk, .do or .po file.
+type sectorImage struct{}
+
+func (sectorImage) decode(file []byte) ([]byte, error)    { return file, nil }
+func (sectorImage) encode(sectors []byte) ([]byte, error) { return sectors, nil }
+
+// Encode returns the disk as an image file in the format implied by ext, such
+// as ".dsk", ".po", ".2mg" or ".nib", regardless of the format it was loaded
+// from.
+func (dsk *Diskette) Encode(ext string) ([]byte, error) {
+	switch strings.ToLower(ext) {
+	case ".dsk", ".do":
+		return dsk.sectorsInOrder(DOSOrder), nil
+	case ".po":
+		if dsk.SectorsPerTrack() != 16 {
+			return nil, fmt.Errorf("cannot encode %d-sector disks as .po images: %w", dsk.SectorsPerTrack(), errors.ErrUnsupported)
+		}
+		return dsk.sectorsInOrder(ProDOSOrder), nil
+	case ".2mg", ".2img":
+		return newTwoIMG(dsk.sectorsInOrder(DOSOrder), dsk.Volume(), dsk.comment), nil
+	case ".nib":
+		nib := &nibImage{sectorsPerTrack: int(dsk.SectorsPerTrack()), volume: byte(dsk.Volume())}
+		return nib.encode(dsk.sectorsInOrder(DOSOrder))
+	default:
+		return nil, fmt.Errorf("cannot encode %s images: %w", ext, errors.ErrUnsupported)
+	}
+}
+
+// sectorsInOrder returns a copy of the sector image arranged in order.
+func (dsk *Diskette) sectorsInOrder(order SectorOrder) []byte {
+	size := uint(dsk.SectorSize())
+	spt := dsk.SectorsPerTrack()
+	image := make([]byte, dsk.NumTracks()*spt*size)
+	for t := uint(0); t < dsk.NumTracks(); t++ {
+		for s := uint(0); s < spt; s++ {
+			copy(image[(t*spt+order.position(s, spt))*size:], dsk.rawSector(t, s))
+		}
+	}
+	return image
+}
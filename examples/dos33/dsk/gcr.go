@@ -0,0 +1,380 @@
+package dsk
+
+/// Group Code Recording
+/*
+http://fileformats.archiveteam.org/wiki/Apple_II_nibble_encoding
+"Beneath Apple DOS" Chapter 3
+
+The Disk II controller can only read "disk bytes" (nibbles) that have the high
+bit set and no more than one pair of consecutive zero bits. Data is therefore
+recorded in groups of bits, each translated to a valid disk byte.
+
+Each sector on a track is made of an address field and a data field, separated
+by self-sync $FF bytes:
+
+Address field
+----
+D5 AA 96     prologue (D5 AA B5 for 13-sector disks)
+XX YY        volume, in "4 and 4" encoding
+XX YY        track
+XX YY        sector (the physical sector number)
+XX YY        checksum: volume ^ track ^ sector
+DE AA EB     epilogue
+
+Data field
+----
+D5 AA AD     prologue
+...          342 "6 and 2" nibbles (410 "5 and 3" nibbles for 13 sectors)
+XX           checksum
+DE AA EB     epilogue
+
+Every data nibble is the XOR of its value and the previous value, so the
+running value after the checksum nibble is always zero.
+
+DOS 3.3 interleaves its logical sectors across the physical sectors of a
+16-sector track, so a sector image stores physical sector dos33Physical[s] at
+position s. DOS 3.2 numbers its 13 sectors without interleave.
+*/
+
+var diskBytes62 = [64]byte{
+	0x96, 0x97, 0x9A, 0x9B, 0x9D, 0x9E, 0x9F, 0xA6,
+	0xA7, 0xAB, 0xAC, 0xAD, 0xAE, 0xAF, 0xB2, 0xB3,
+	0xB4, 0xB5, 0xB6, 0xB7, 0xB9, 0xBA, 0xBB, 0xBC,
+	0xBD, 0xBE, 0xBF, 0xCB, 0xCD, 0xCE, 0xCF, 0xD3,
+	0xD6, 0xD7, 0xD9, 0xDA, 0xDB, 0xDC, 0xDD, 0xDE,
+	0xDF, 0xE5, 0xE6, 0xE7, 0xE9, 0xEA, 0xEB, 0xEC,
+	0xED, 0xEE, 0xEF, 0xF2, 0xF3, 0xF4, 0xF5, 0xF6,
+	0xF7, 0xF9, 0xFA, 0xFB, 0xFC, 0xFD, 0xFE, 0xFF,
+}
+
+var diskBytes53 = [32]byte{
+	0xAB, 0xAD, 0xAE, 0xAF, 0xB5, 0xB6, 0xB7, 0xBA,
+	0xBB, 0xBD, 0xBE, 0xBF, 0xD6, 0xD7, 0xDA, 0xDB,
+	0xDD, 0xDE, 0xDF, 0xEA, 0xEB, 0xED, 0xEE, 0xEF,
+	0xF5, 0xF6, 0xF7, 0xFA, 0xFB, 0xFD, 0xFE, 0xFF,
+}
+
+// dos33Physical maps DOS 3.3 logical sectors to physical sectors.
+var dos33Physical = [16]byte{0x0, 0xD, 0xB, 0x9, 0x7, 0x5, 0x3, 0x1, 0xE, 0xC, 0xA, 0x8, 0x6, 0x4, 0x2, 0xF}
+
+const invalidDiskByte = 0xFF
+
+var (
+	valueOf62 = inverseDiskBytes(diskBytes62[:])
+	valueOf53 = inverseDiskBytes(diskBytes53[:])
+)
+
+func inverseDiskBytes(diskBytes []byte) (inverse [256]byte) {
+	for i := range inverse {
+		inverse[i] = invalidDiskByte
+	}
+	for value, diskByte := range diskBytes {
+		inverse[diskByte] = byte(value)
+	}
+	return
+}
+
+const (
+	addressMark16 = 0x96
+	addressMark13 = 0xB5
+	dataMark      = 0xAD
+
+	nibbles62 = 86 + 256 // not counting the checksum
+	nibbles53 = 154 + 256
+)
+
+// decode44 decodes a byte from its "4 and 4" pair of disk bytes.
+func decode44(odd, even byte) byte { return (odd<<1 | 1) & even }
+
+// decodeTrack returns every readable sector on a track of nibbles, keyed by
+// the physical sector number in its address field, and the volume number
+// recorded in the address fields.
+//
+// A track is circular, so nibbles should hold it twice over to catch the
+// sector that straddles the end.
+func decodeTrack(nibbles []byte, sectorsPerTrack int) (sectors map[byte][]byte, volume byte) {
+	addressMark, decodeData := byte(addressMark16), decode62
+	if sectorsPerTrack == 13 {
+		addressMark, decodeData = addressMark13, decode53
+	}
+
+	// The data field follows its address field after a short gap.
+	const maxGap = 64
+
+	sectors = make(map[byte][]byte)
+	for i := 0; i+3+8 <= len(nibbles); i++ {
+		if !hasPrologue(nibbles[i:], addressMark) {
+			continue
+		}
+		field := nibbles[i+3:]
+		vol := decode44(field[0], field[1])
+		track := decode44(field[2], field[3])
+		sector := decode44(field[4], field[5])
+		if vol^track^sector != decode44(field[6], field[7]) || int(sector) >= sectorsPerTrack {
+			continue
+		}
+		i += 3 + 8
+
+		for gap := 0; gap < maxGap && i+3 <= len(nibbles); gap, i = gap+1, i+1 {
+			if !hasPrologue(nibbles[i:], dataMark) {
+				continue
+			}
+			if data, ok := decodeData(nibbles[i+3:]); ok {
+				if _, seen := sectors[sector]; !seen {
+					sectors[sector] = data
+					volume = vol
+				}
+			}
+			break
+		}
+	}
+	return
+}
+
+func hasPrologue(nibbles []byte, mark byte) bool {
+	return len(nibbles) >= 3 && nibbles[0] == 0xD5 && nibbles[1] == 0xAA && nibbles[2] == mark
+}
+
+// decode62 decodes the 256 bytes of a "6 and 2" data field.
+//
+// The first 86 values hold the low two bits of each byte (swapped), three to a
+// value, and the following 256 values hold the high six bits.
+func decode62(nibbles []byte) ([]byte, bool) {
+	const twos = 86
+	if len(nibbles) < nibbles62+1 {
+		return nil, false
+	}
+
+	var low [twos]byte
+	data := make([]byte, 256)
+	checksum := byte(0)
+	for i := 0; i <= nibbles62; i++ {
+		value := valueOf62[nibbles[i]]
+		if value == invalidDiskByte {
+			return nil, false
+		}
+		checksum ^= value
+		switch {
+		case i < twos:
+			low[i] = checksum
+		case i < nibbles62:
+			data[i-twos] = checksum << 2
+		}
+	}
+	if checksum != 0 {
+		return nil, false
+	}
+
+	for i := range data {
+		bits := low[i%twos] >> (2 * (i / twos))
+		data[i] |= (bits&0b01)<<1 | (bits&0b10)>>1
+	}
+	return data, true
+}
+
+// decode53 decodes the 256 bytes of a "5 and 3" data field.
+//
+// The first 154 values, stored in reverse, hold the low three bits of each
+// byte, and the following 256 values hold the high five bits. The low bits of
+// five bytes are spread over three values.
+func decode53(nibbles []byte) ([]byte, bool) {
+	const (
+		threes = 154
+		chunk  = 51
+	)
+	if len(nibbles) < nibbles53+1 {
+		return nil, false
+	}
+
+	var low [threes]byte
+	var high [256]byte
+	checksum := byte(0)
+	for i := 0; i <= nibbles53; i++ {
+		value := valueOf53[nibbles[i]]
+		if value == invalidDiskByte {
+			return nil, false
+		}
+		checksum ^= value
+		switch {
+		case i < threes:
+			low[threes-1-i] = checksum
+		case i < nibbles53:
+			high[i-threes] = checksum << 3
+		}
+	}
+	if checksum != 0 {
+		return nil, false
+	}
+
+	data := make([]byte, 0, 256)
+	for i := chunk - 1; i >= 0; i-- {
+		three1, three2, three3 := low[i], low[chunk+i], low[2*chunk+i]
+		three4 := (three1&0b010)<<1 | (three2 & 0b010) | (three3&0b010)>>1
+		three5 := (three1&0b001)<<2 | (three2&0b001)<<1 | (three3 & 0b001)
+		data = append(data,
+			high[i]|(three1>>2)&0b111,
+			high[chunk+i]|(three2>>2)&0b111,
+			high[2*chunk+i]|(three3>>2)&0b111,
+			high[3*chunk+i]|three4,
+			high[4*chunk+i]|three5,
+		)
+	}
+	data = append(data, high[255]|low[threes-1]&0b111)
+	return data, true
+}
+
+// decodeNibbleTracks decodes every track into a DOS-ordered sector image and
+// returns it with the volume number found in the address fields.
+// Sectors that cannot be read are left zeroed.
+func decodeNibbleTracks(tracks [][]byte, sectorsPerTrack int) (image []byte, volume byte) {
+	const sectorSize = 256
+	image = make([]byte, len(tracks)*sectorsPerTrack*sectorSize)
+	for t, nibbles := range tracks {
+		sectors, vol := decodeTrack(nibbles, sectorsPerTrack)
+		if volume == 0 {
+			volume = vol
+		}
+		for position := 0; position < sectorsPerTrack; position++ {
+			if data, ok := sectors[physicalSector(position, sectorsPerTrack)]; ok {
+				copy(image[(t*sectorsPerTrack+position)*sectorSize:], data)
+			}
+		}
+	}
+	return
+}
+
+// physicalSector returns the physical sector written for the sector at
+// position in a DOS-ordered track.
+func physicalSector(position, sectorsPerTrack int) byte {
+	if sectorsPerTrack == 16 {
+		return dos33Physical[position]
+	}
+	return byte(position)
+}
+
+// sectorsPerNibbleTrack guesses whether tracks were written with 16 or 13
+// sectors by looking for their address field prologues.
+func sectorsPerNibbleTrack(tracks [][]byte) int {
+	count16, count13 := 0, 0
+	for _, nibbles := range tracks {
+		for i := range nibbles {
+			if hasPrologue(nibbles[i:], addressMark16) {
+				count16++
+			} else if hasPrologue(nibbles[i:], addressMark13) {
+				count13++
+			}
+		}
+	}
+	if count13 > count16 {
+		return 13
+	}
+	return 16
+}
+
+// encode44 encodes a byte as a "4 and 4" pair of disk bytes: the odd bits,
+// then the even bits, each interleaved with ones.
+func encode44(value byte) (odd, even byte) { return value>>1 | 0xAA, value | 0xAA }
+
+// encode62 returns the "6 and 2" data field nibbles for 256 bytes, including
+// the checksum. It is the inverse of decode62.
+func encode62(data []byte) []byte {
+	const twos = 86
+	swap := func(bits byte) byte { return (bits&0b01)<<1 | (bits&0b10)>>1 }
+
+	values := make([]byte, nibbles62)
+	for i, b := range data {
+		values[i%twos] |= swap(b&0b11) << (2 * (i / twos))
+		values[twos+i] = b >> 2
+	}
+	return chainNibbles(values, diskBytes62[:])
+}
+
+// encode53 returns the "5 and 3" data field nibbles for 256 bytes, including
+// the checksum. It is the inverse of decode53.
+func encode53(data []byte) []byte {
+	const (
+		threes = 154
+		chunk  = 51
+	)
+	var low [threes]byte
+	var high [256]byte
+	for i := 0; i < chunk; i++ {
+		d := data[5*(chunk-1-i):][:5]
+		for j := 0; j < 5; j++ {
+			high[j*chunk+i] = d[j] >> 3
+		}
+		low[i] = (d[0]&0b111)<<2 | (d[3]>>2&1)<<1 | d[4]>>2&1
+		low[chunk+i] = (d[1]&0b111)<<2 | (d[3]>>1&1)<<1 | d[4]>>1&1
+		low[2*chunk+i] = (d[2]&0b111)<<2 | (d[3]&1)<<1 | d[4]&1
+	}
+	high[255] = data[255] >> 3
+	low[threes-1] = data[255] & 0b111
+
+	values := make([]byte, 0, nibbles53)
+	for i := threes - 1; i >= 0; i-- {
+		values = append(values, low[i])
+	}
+	values = append(values, high[:]...)
+	return chainNibbles(values, diskBytes53[:])
+}
+
+// chainNibbles XORs each value with the one before it, translates the results
+// to disk bytes, then appends the checksum.
+func chainNibbles(values, diskBytes []byte) []byte {
+	nibbles := make([]byte, 0, len(values)+1)
+	prev := byte(0)
+	for _, value := range values {
+		nibbles = append(nibbles, diskBytes[value^prev])
+		prev = value
+	}
+	return append(nibbles, diskBytes[prev])
+}
+
+// encodeNibbleTrack returns trackSize nibbles holding a freshly formatted
+// track, where sector returns the data for each position of a DOS-ordered
+// track.
+func encodeNibbleTrack(volume, track byte, sectorsPerTrack, trackSize int, sector func(position int) []byte) []byte {
+	const (
+		syncByte = 0xFF
+		gap1     = 48 // before the first sector
+		gap2     = 5  // between the address and data fields
+	)
+	addressMark, encodeData := byte(addressMark16), encode62
+	if sectorsPerTrack == 13 {
+		addressMark, encodeData = addressMark13, encode53
+	}
+	sync := func(nibbles []byte, n int) []byte {
+		for ; n > 0; n-- {
+			nibbles = append(nibbles, syncByte)
+		}
+		return nibbles
+	}
+
+	fields := make([][]byte, sectorsPerTrack)
+	fieldsSize := 0
+	for position := range fields {
+		s := physicalSector(position, sectorsPerTrack)
+		field := []byte{0xD5, 0xAA, addressMark}
+		for _, value := range []byte{volume, track, s, volume ^ track ^ s} {
+			odd, even := encode44(value)
+			field = append(field, odd, even)
+		}
+		field = append(field, 0xDE, 0xAA, 0xEB)
+		field = sync(field, gap2)
+		field = append(field, 0xD5, 0xAA, dataMark)
+		field = append(field, encodeData(sector(position))...)
+		field = append(field, 0xDE, 0xAA, 0xEB)
+		fields[s] = field
+		fieldsSize += len(field)
+	}
+
+	// Spread what's left of the track between the sectors.
+	gap3 := max(0, (trackSize-gap1-fieldsSize)/sectorsPerTrack)
+
+	nibbles := sync(make([]byte, 0, trackSize), gap1)
+	for _, field := range fields {
+		nibbles = append(nibbles, field...)
+		nibbles = sync(nibbles, gap3)
+	}
+	return sync(nibbles, trackSize-len(nibbles))[:trackSize]
+}
@@ -0,0 +1,69 @@
+package dsk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+// These fixtures do not come from the encoders here or in dsktest.
+var (
+	// blankSector62 is track 0, sector 0 of volume 254 holding only zeros, as
+	// Beneath Apple DOS lays out its address and data fields.
+	blankSector62 = slices.Concat(
+		[]byte{0xD5, 0xAA, 0x96, 0xFF, 0xFE, 0xAA, 0xAA, 0xAA, 0xAA, 0xFF, 0xFE, 0xDE, 0xAA, 0xEB},
+		[]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		[]byte{0xD5, 0xAA, 0xAD}, bytes.Repeat([]byte{0x96}, 343), []byte{0xDE, 0xAA, 0xEB},
+	)
+
+	// field62 is the data field, without prologue or epilogue, that DOS 3.3
+	// writes for fixtureSector, worked out by following its PRENIB16 and
+	// WRITE16 routines.
+	field62, _ = hex.DecodeString("" +
+		"CD96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96" +
+		"FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96" +
+		"FB96EB96FB96EB96FB96EB96FB96EB96FB96EB96FB96AB979EAFBEABFDBAEDB7" +
+		"F6D3E7FBECDED6D7DCEAF9E5CEF4B4F6B7FBB3D3AD9B9697AF9EBEABF4CEEDB7" +
+		"FFBCE7FBDEECD6D7EADCF9E5BAFDB4FFB7ADF6A6CB9B96979ECEABF9AFBAEDB7" +
+		"F6ECCBE7FFDED6D7DCFDE5BEEAF4B4F6B7ADFFB3CB9B9697CE9EABF9BAAFEDB7" +
+		"ECF6CBE7DEFFD6D7FDDCE5BEF4EAB4B3F2CBF6A6AD9B96979EAFF9ABCEF4B4F2" +
+		"BCFFE7CBECDED6D7DCEABEE5FDBAEDBCF2CBB3FFAD9B9697AF9EF9ABBAFDB4F2" +
+		"D3F6E7CBDEECD6D7EADCBEE5F4CEEDD3F2ADBCA6FB9B96979EFDABBEAFF4B4F2" +
+		"BCECFBE7D3DED6D7DCCEE5F9EABAEDBCF2ADD3B3FB9B9697FD9EABBEF4AFB4F2" +
+		"ECBCFBE7DED3D6D7CEDCE5F9BAEAEDB3B7FBBCA6AD9BD6")
+)
+
+// fixtureSector returns the sector encoded in field62.
+func fixtureSector() []byte {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(4*i*i + i/2)
+	}
+	return data
+}
+
+func TestDecodeKnownNibbles(t *testing.T) {
+	sectors, volume := decodeTrack(blankSector62, 16)
+	if volume != 254 || !slices.Equal(sectors[0], make([]byte, 256)) {
+		t.Errorf("Expected a blank sector 0 on volume 254, got volume %d and % X", volume, sectors[0])
+	}
+
+	if data, ok := decode62(field62); !ok || !slices.Equal(data, fixtureSector()) {
+		t.Errorf("Expected the fixture sector, got %v % X", ok, data)
+	}
+}
+
+func TestEncodeKnownNibbles(t *testing.T) {
+	if field := encode62(fixtureSector()); !slices.Equal(field, field62) {
+		t.Errorf("Expected the data field DOS 3.3 writes, got % X", field)
+	}
+
+	// dsktest must write the same fields that are decoded here.
+	nib := dsktest.NIB(make([]byte, 35*16*256), 16)
+	if !bytes.Contains(nib[:6656], blankSector62[:14]) || !bytes.Contains(nib[:6656], blankSector62[19:]) {
+		t.Error("Expected dsktest to write the address and data fields of a blank sector")
+	}
+}
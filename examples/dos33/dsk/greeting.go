@@ -0,0 +1,174 @@
+package dsk
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+/// Greeting Program and Volume Number
+/*
+INIT stores the name of the program to run at boot, the greeting program, in
+the DOS image on the disk: 30 characters of high ASCII, padded with spaces, at
+track 1, sector 9, $75-$92. The volume number is in the VTOC, at $06.
+
+Both can be changed without booting DOS. A disk with no DOS image, or none
+that keeps a name where DOS 3.3 does, has no greeting program to change.
+*/
+
+const dosTracks = 3 // tracks 0-2 hold DOS
+
+// greeting is where DOS keeps the name of the program it runs at boot, which
+// differs from disk to disk: track 1, sector 9, $75-$92.
+var greeting = struct{ track, sector, offset, length uint }{1, 9, 0x75, 30}
+
+// ErrNoGreeting is why a disk has no greeting program to read or change.
+var ErrNoGreeting = errors.New("no DOS image with a greeting program")
+
+// Greeting returns the name of the program DOS runs when dsk boots.
+func (dsk *Diskette) Greeting() (string, error) {
+	region, err := dsk.greetingRegion()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, b := range region {
+		sb.WriteByte(b &^ 0x80)
+	}
+	return strings.TrimRight(sb.String(), " "), nil
+}
+
+// SetGreeting changes the program DOS runs when dsk boots. The name follows
+// the rules for DOS file names: it starts with a letter, is at most 30
+// characters long and has no commas.
+func (dsk *Diskette) SetGreeting(name string) error {
+	if err := dsk.CheckGreeting(name); err != nil {
+		return err
+	}
+	region, err := dsk.greetingRegion()
+	if err != nil {
+		return err
+	}
+
+	prev := slices.Clone(region)
+	for i := range region {
+		region[i] = ' ' | 0x80
+		if i < len(name) {
+			region[i] = name[i] | 0x80
+		}
+	}
+	if err := dsk.save(); err != nil {
+		copy(region, prev)
+		return err
+	}
+	return nil
+}
+
+// CheckGreeting reports why SetGreeting(name) would fail without saving, if it
+// would.
+func (dsk *Diskette) CheckGreeting(name string) error {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
+	if err := validGreeting(name); err != nil {
+		return err
+	}
+	_, err := dsk.greetingRegion()
+	return err
+}
+
+// greetingRegion returns the bytes of the greeting program's name, in the DOS
+// image on dsk.
+func (dsk *Diskette) greetingRegion() ([]byte, error) {
+	if dsk.bootTracksBlank() || dsk.bootTracksFree() {
+		return nil, fmt.Errorf("%s: %w: its boot tracks are empty", dsk.name, ErrNoGreeting)
+	}
+	sector := dsk.rawSector(greeting.track, greeting.sector)
+	region := sector[greeting.offset:][:greeting.length]
+	for _, b := range region {
+		// DOS stores names in high ASCII, and no name holds a control character.
+		if b < 0xA0 || b == 0xFF {
+			return nil, fmt.Errorf("%s: %w: track %d, sector %d, $%02X-$%02X does not hold a name",
+				dsk.name, ErrNoGreeting, greeting.track, greeting.sector,
+				greeting.offset, greeting.offset+greeting.length-1)
+		}
+	}
+	return region, nil
+}
+
+// validGreeting reports why name cannot be a greeting program, if it cannot.
+func validGreeting(name string) error {
+	switch {
+	case name == "":
+		return errors.New("greeting program: no name given")
+	case len(name) > int(greeting.length):
+		return fmt.Errorf("greeting program %q: longer than %d characters", name, greeting.length)
+	case name[0] < 'A' || name[0] > 'Z':
+		return fmt.Errorf("greeting program %q: must start with a letter from A to Z", name)
+	}
+	for _, c := range name {
+		if c < ' ' || c > '~' || c == ',' {
+			return fmt.Errorf("greeting program %q: %q cannot be in a DOS file name", name, c)
+		}
+	}
+	return nil
+}
+
+// SetVolume changes the volume number of dsk, which must be from 1 to 254.
+func (dsk *Diskette) SetVolume(volume int) error {
+	if err := dsk.CheckVolume(volume); err != nil {
+		return err
+	}
+	prev := dsk.vtoc[0x06]
+	setVolume := func(volume byte) {
+		dsk.vtoc[0x06] = volume
+		if img, ok := dsk.format.(*twoIMGImage); ok {
+			img.setVolume(volume)
+		}
+	}
+	setVolume(byte(volume))
+	if err := dsk.save(); err != nil {
+		setVolume(prev)
+		return err
+	}
+	return nil
+}
+
+// CheckVolume reports why SetVolume(volume) would fail without saving, if it
+// would.
+func (dsk *Diskette) CheckVolume(volume int) error {
+	if !dsk.writable() {
+		return dsk.errReadonly()
+	}
+	if volume < 1 || volume > 254 {
+		return fmt.Errorf("volume %d: must be from 1 to 254", volume)
+	}
+	return nil
+}
+
+// bootTracksBlank reports whether the boot tracks hold nothing but zeros.
+func (dsk *Diskette) bootTracksBlank() bool {
+	for t := uint(0); t < dosTracks; t++ {
+		for s := uint(0); s < dsk.SectorsPerTrack(); s++ {
+			for _, b := range dsk.rawSector(t, s) {
+				if b != 0 {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// bootTracksFree reports whether the VTOC frees the boot tracks for files.
+func (dsk *Diskette) bootTracksFree() bool {
+	for t := uint(0); t < dosTracks; t++ {
+		for s := uint(0); s < dsk.SectorsPerTrack(); s++ {
+			if !dsk.sectorFree(t, s) {
+				return false
+			}
+		}
+	}
+	return true
+}
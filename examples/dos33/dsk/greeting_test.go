@@ -0,0 +1,90 @@
+package dsk
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+// bootImage returns a standard image with code on its boot tracks, and the
+// greeting program's name set to greeting.
+func bootImage(geo dsktest.Geometry, greeting string) []byte {
+	image := dsktest.Image(geo, 254)
+	for i := 0; i < 3*geo.Sectors*dsktest.SectorSize; i++ {
+		image[i] = byte(i*7 + 1)
+	}
+	name := image[(1*geo.Sectors+9)*dsktest.SectorSize+0x75:][:30]
+	for i := range name {
+		name[i] = ' ' | 0x80
+		if i < len(greeting) {
+			name[i] = greeting[i] | 0x80
+		}
+	}
+	return image
+}
+
+func TestGreeting(t *testing.T) {
+	path := writeImage(t, "BOOT.dsk", bootImage(dsktest.Standard, "HELLO"))
+	dsk, err := LoadDiskette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := dsk.Greeting(); err != nil || name != "HELLO" {
+		t.Fatalf("Greeting() = %q, %v; want HELLO", name, err)
+	}
+	for _, bad := range []string{"", "1ST", "A,B", "MENU\x07", strings.Repeat("A", 31)} {
+		if err := dsk.SetGreeting(bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+	if err := dsk.SetGreeting("MENU"); err != nil {
+		t.Fatal(err)
+	}
+
+	if dsk, err = LoadDiskette(path); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := dsk.Greeting(); err != nil || name != "MENU" {
+		t.Fatalf("Greeting() = %q, %v; want MENU", name, err)
+	}
+
+	data := loadImage(t, "DATA.dsk", dsktest.Image(dsktest.Standard, 254))
+	if err := data.SetGreeting("HELLO"); !errors.Is(err, ErrNoGreeting) {
+		t.Fatal("Expected a disk without DOS to have no greeting program, got", err)
+	}
+}
+
+func TestSetVolume(t *testing.T) {
+	sectors := dsktest.Image(dsktest.Standard, 254)
+	path := writeImage(t, "DISK.2mg", newTwoIMG(sectors, 254, ""))
+	dsk, err := LoadDiskette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []int{0, 255} {
+		if err := dsk.SetVolume(bad); err == nil {
+			t.Errorf("Expected volume %d to be refused", bad)
+		}
+	}
+	if err := dsk.SetVolume(100); err != nil {
+		t.Fatal(err)
+	}
+
+	if dsk, err = LoadDiskette(path); err != nil {
+		t.Fatal(err)
+	}
+	if dsk.Volume() != 100 {
+		t.Fatal("Expected volume 100, got", dsk.Volume())
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags := binary.LittleEndian.Uint32(file[0x10:]); flags&0xFF != 100 {
+		t.Fatalf("Expected the 2IMG header to have volume 100, got flags $%X", flags)
+	}
+}
@@ -0,0 +1,302 @@
+package dsk
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/// Known Software
+/*
+A HashDB identifies disks and files by their hashes, as the public catalogs of
+Apple II disk images do. It is loaded from a TSV file whose first line names
+its columns, or from a JSON array of objects with the same keys:
+
+  name    what the disk or file is, such as "Oregon Trail (1985) side A"
+  crc32   any of the hashes, in hex, as many as the catalog has
+  md5
+  sha1
+  sha256
+
+Other columns and keys are ignored, and lines starting with # are comments.
+An entry may be of a whole disk or of one file: Identify hashes the disk's
+image file as it is on the host, in whatever format it was loaded from, which
+is what catalogs list, and also the disk as a DOS-ordered .dsk image, so that
+a disk converted from another format is still known. It hashes the contents of
+each file on the disk too, as [Diskette.ReadAll] gives them.
+*/
+
+// hashAlgorithms are the hashes a HashDB can hold, in the order reported.
+var hashAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"crc32", func() hash.Hash { return crc32.NewIEEE() }},
+	{"md5", md5.New},
+	{"sha1", sha1.New},
+	{"sha256", sha256.New},
+}
+
+// HashDB is a catalog of known disks and files by their hashes.
+type HashDB struct {
+	names map[string][]string // by "algorithm:hex"
+	size  int                 // entries
+
+	mu    sync.Mutex
+	found map[*Diskette]*Identity // cached by Identify
+}
+
+// Hashes are the hashes of a disk or file in hex, by algorithm.
+type Hashes map[string]string
+
+// Identity is what [HashDB.Identify] knows of a disk.
+type Identity struct {
+	generation uint64 // of the disk when identified
+
+	Names     []string // of the disk, from the entries that match either of its hashes
+	Hashes    Hashes   // of the image file, see [Diskette.ImageFile]
+	DSKHashes Hashes   // of the disk as a .dsk image
+	Files     []FileIdentity
+}
+
+// FileIdentity is what [HashDB.Identify] knows of a file on a disk.
+type FileIdentity struct {
+	Name   string   // as in paths, see [Diskette.PathNames]
+	Names  []string // from the entries that match its hashes
+	Hashes Hashes
+}
+
+// LoadHashDB reads a HashDB from a .json file or, with any other extension,
+// a TSV file.
+func LoadHashDB(path string) (*HashDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ParseHashDBJSON(f)
+	}
+	return ParseHashDBTSV(f)
+}
+
+// ParseHashDBTSV reads a HashDB from tab-separated values with a header line.
+func ParseHashDBTSV(r io.Reader) (*HashDB, error) {
+	db := newHashDB()
+	var columns []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if columns == nil {
+			for _, field := range fields {
+				columns = append(columns, strings.ToLower(strings.TrimSpace(field)))
+			}
+			if !slices.Contains(columns, "name") {
+				return nil, fmt.Errorf("line %d: no name column in the header", n)
+			}
+			continue
+		}
+		entry := make(map[string]string)
+		for i, field := range fields {
+			if i < len(columns) {
+				entry[columns[i]] = strings.TrimSpace(field)
+			}
+		}
+		if err := db.add(entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// ParseHashDBJSON reads a HashDB from a JSON array of entries.
+func ParseHashDBJSON(r io.Reader) (*HashDB, error) {
+	var entries []map[string]any
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+	db := newHashDB()
+	for i, entry := range entries {
+		fields := make(map[string]string)
+		for key, value := range entry {
+			if s, ok := value.(string); ok {
+				fields[strings.ToLower(key)] = strings.TrimSpace(s)
+			}
+		}
+		if err := db.add(fields); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return db, nil
+}
+
+func newHashDB() *HashDB {
+	return &HashDB{names: make(map[string][]string), found: make(map[*Diskette]*Identity)}
+}
+
+// add adds the entry with the given fields, which must have a name and at
+// least one hash.
+func (db *HashDB) add(fields map[string]string) error {
+	name := fields["name"]
+	if name == "" {
+		return errors.New("no name")
+	}
+	hashes := 0
+	for _, alg := range hashAlgorithms {
+		sum := strings.ToLower(fields[alg.name])
+		if sum == "" {
+			continue
+		}
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != alg.new().Size() {
+			return fmt.Errorf("%s: %q is not a %s", name, sum, alg.name)
+		}
+		key := alg.name + ":" + sum
+		if !slices.Contains(db.names[key], name) {
+			db.names[key] = append(db.names[key], name)
+		}
+		hashes++
+	}
+	if hashes == 0 {
+		return fmt.Errorf("%s: no hashes", name)
+	}
+	db.size++
+	return nil
+}
+
+// Len returns the number of entries in db.
+func (db *HashDB) Len() int { return db.size }
+
+// lookup returns the names of the entries that match any of hashes.
+func (db *HashDB) lookup(hashes Hashes) []string {
+	var names []string
+	for _, alg := range hashAlgorithms {
+		for _, name := range db.names[alg.name+":"+hashes[alg.name]] {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Identify returns the hashes of dsk and its files and the entries of db that
+// match them. It is hashed again only once dsk changes.
+func (db *HashDB) Identify(dsk *Diskette) (*Identity, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if id, ok := db.found[dsk]; ok && id.generation == dsk.Generation() {
+		return id, nil
+	}
+
+	id := &Identity{generation: dsk.Generation()}
+	file, err := dsk.ImageFile()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dsk.Name(), err)
+	}
+	image, err := dsk.Encode(".dsk")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dsk.Name(), err)
+	}
+	id.Hashes, id.DSKHashes = hashesOf(file), hashesOf(image)
+	id.Names = db.lookup(id.Hashes)
+	for _, name := range db.lookup(id.DSKHashes) {
+		if !slices.Contains(id.Names, name) {
+			id.Names = append(id.Names, name)
+		}
+	}
+	names := dsk.PathNames()
+	catalog, err := dsk.ReadCatalog()
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range catalog {
+		if file.IsDeleted() {
+			continue
+		}
+		data, err := dsk.ReadAll(file)
+		if err != nil {
+			continue
+		}
+		hashes := hashesOf(data)
+		id.Files = append(id.Files, FileIdentity{Name: names[i], Names: db.lookup(hashes), Hashes: hashes})
+	}
+	db.found[dsk] = id
+	return id, nil
+}
+
+// hashesOf returns every hash of data.
+func hashesOf(data []byte) Hashes {
+	hashes := make(Hashes)
+	for _, alg := range hashAlgorithms {
+		h := alg.new()
+		h.Write(data)
+		hashes[alg.name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes
+}
+
+// Known returns the names matched by the disk and its files, each file's as
+// "FILE: name", or nothing if none matched.
+func (id *Identity) Known() []string {
+	known := slices.Clone(id.Names)
+	for _, file := range id.Files {
+		for _, name := range file.Names {
+			known = append(known, file.Name+": "+name)
+		}
+	}
+	return known
+}
+
+// IdentifyReport returns the contents of IDENTIFY.txt: what db knows dsk and
+// its files to be, and all their hashes, ready to be added to a catalog.
+func IdentifyReport(db *HashDB, dsk *Diskette) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Hash database: %d entries\n\n", db.Len())
+	id, err := db.Identify(dsk)
+	if err != nil {
+		fmt.Fprintf(&sb, "Disk %s: cannot be hashed: %v\n", dsk.Name(), err)
+		return sb.String()
+	}
+
+	writeHashes := func(hashes Hashes) {
+		for _, alg := range hashAlgorithms {
+			fmt.Fprintf(&sb, "  %-6s %s\n", alg.name, hashes[alg.name])
+		}
+	}
+	write := func(label string, names []string, hashes Hashes) {
+		if len(names) == 0 {
+			fmt.Fprintf(&sb, "%s: unknown\n", label)
+		} else {
+			fmt.Fprintf(&sb, "%s: %s\n", label, strings.Join(names, "; "))
+		}
+		writeHashes(hashes)
+	}
+	write("Disk "+dsk.Name()+" ("+filepath.Base(dsk.path)+")", id.Names, id.Hashes)
+	sb.WriteString("As .dsk:\n")
+	writeHashes(id.DSKHashes)
+	for _, file := range id.Files {
+		sb.WriteString("\n")
+		write(file.Name, file.Names, file.Hashes)
+	}
+	return sb.String()
+}
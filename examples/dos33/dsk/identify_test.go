@@ -0,0 +1,120 @@
+package dsk
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"fmt"
+	"hash/crc32"
+	"slices"
+	"strings"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestHashDB(t *testing.T) {
+	image := dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: hiText("HI")},
+		dsktest.File{Name: "OTHER", Type: dsktest.Text, Data: hiText("BYE")},
+	)
+	dsk := loadImage(t, "DISK.dsk", image)
+	hello, err := dsk.ReadAll(dsk.FindFile("HELLO"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tsv := fmt.Sprintf("# A catalog\nName\tPublisher\tMD5\tCRC32\n"+
+		"The Disk\tNobody\t%x\t\n"+
+		"Greeting\t\t\t%08x\n", md5.Sum(image), crc32.ChecksumIEEE(hello))
+	json := fmt.Sprintf(`[{"name": "The Disk", "md5": "%x"}, {"name": "Greeting", "sha1": "%x", "year": 1984}]`,
+		md5.Sum(image), sha1.Sum(hello))
+
+	for format, parse := range map[string]func() (*HashDB, error){
+		"TSV":  func() (*HashDB, error) { return ParseHashDBTSV(strings.NewReader(tsv)) },
+		"JSON": func() (*HashDB, error) { return ParseHashDBJSON(strings.NewReader(json)) },
+	} {
+		db, err := parse()
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		if db.Len() != 2 {
+			t.Errorf("%s: expected 2 entries, got %d", format, db.Len())
+		}
+		id, err := db.Identify(dsk)
+		if err != nil {
+			t.Fatal(format, err)
+		}
+		if want := []string{"The Disk", "HELLO: Greeting"}; !slices.Equal(id.Known(), want) {
+			t.Errorf("%s: expected %q, got %q", format, want, id.Known())
+		}
+		if want := fmt.Sprintf("%x", sha1.Sum(hello)); id.Files[0].Hashes["sha1"] != want {
+			t.Errorf("%s: expected HELLO's sha1 to be %s, got %s", format, want, id.Files[0].Hashes["sha1"])
+		}
+	}
+
+	for _, bad := range []string{
+		"sha1\nabc\n",                 // no name column
+		"name\tsha1\nThe Disk\t\n",    // no hashes
+		"name\tmd5\nThe Disk\tabcd\n", // too short for an MD5
+	} {
+		if _, err := ParseHashDBTSV(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestIdentifyAfterChange(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: hiText("HI")},
+	))
+	hello, _ := dsk.ReadAll(dsk.FindFile("HELLO"))
+	db, err := ParseHashDBTSV(strings.NewReader(fmt.Sprintf("name\tsha1\nGreeting\t%x\n", sha1.Sum(hello))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := db.Identify(dsk); err != nil || len(id.Known()) != 1 {
+		t.Fatal("Expected HELLO to be known, got", id, err)
+	}
+	if err := dsk.Delete(dsk.FindFile("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := db.Identify(dsk); err != nil || len(id.Known()) != 0 {
+		t.Error("Expected nothing known once HELLO is deleted, got", id, err)
+	}
+}
+
+func TestIdentifyImageFile(t *testing.T) {
+	sectors := dsktest.Image(dsktest.Standard, 254)
+	file := newTwoIMG(sectors, 254, "A comment")
+	db, err := ParseHashDBTSV(strings.NewReader(fmt.Sprintf("name\tmd5\nThe 2IMG\t%x\nThe DSK\t%x\n",
+		md5.Sum(file), md5.Sum(sectors))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, load := range map[string]func() *Diskette{
+		"file": func() *Diskette { return loadImage(t, "DISK.2mg", file) },
+		"zip": func() *Diskette {
+			disks, _, err := LoadZip(writeZip(t, map[string][]byte{"DISK.2mg": file}))
+			if err != nil || len(disks) != 1 {
+				t.Fatal(disks, err)
+			}
+			return disks[0]
+		},
+	} {
+		dsk := load()
+		id, err := db.Identify(dsk)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if want := fmt.Sprintf("%x", md5.Sum(file)); id.Hashes["md5"] != want {
+			t.Errorf("%s: expected the md5 of the image file, %s, got %s", name, want, id.Hashes["md5"])
+		}
+		if want := []string{"The 2IMG", "The DSK"}; !slices.Equal(id.Names, want) {
+			t.Errorf("%s: expected %q, got %q", name, want, id.Names)
+		}
+		if report := IdentifyReport(db, dsk); !strings.Contains(report, "Disk DISK (DISK.2mg): The 2IMG; The DSK\n") {
+			t.Errorf("%s: expected the image file in the report:\n%s", name, report)
+		}
+	}
+}
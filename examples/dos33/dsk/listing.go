@@ -0,0 +1,215 @@
+package dsk
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/// BASIC Programs
+/*
+http://fileformats.archiveteam.org/wiki/Applesoft_BASIC_tokenized_file
+http://fileformats.archiveteam.org/wiki/Integer_BASIC_tokenized_file
+
+Both kinds of BASIC file start with the length of the program (LO/HI format).
+
+Applesoft lines
+----
+$00-01 address of the next line, or zero after the last line
+$02-03 line number
+$04-   tokens ($80-$EA) and ASCII characters, ending with $00
+
+Integer BASIC lines
+----
+$00    length of the line
+$01-02 line number
+$03-   tokens ($00-$7F) and Hi-ASCII characters, ending with $01
+
+In Integer BASIC, a Hi-ASCII digit starts a number, which is stored in the
+two bytes that follow it. Characters in strings and REMs are Hi-ASCII, too.
+*/
+
+var applesoftTokens = [...]string{
+	"END", "FOR", "NEXT", "DATA", "INPUT", "DEL", "DIM", "READ",
+	"GR", "TEXT", "PR#", "IN#", "CALL", "PLOT", "HLIN", "VLIN",
+	"HGR2", "HGR", "HCOLOR=", "HPLOT", "DRAW", "XDRAW", "HTAB", "HOME",
+	"ROT=", "SCALE=", "SHLOAD", "TRACE", "NOTRACE", "NORMAL", "INVERSE", "FLASH",
+	"COLOR=", "POP", "VTAB", "HIMEM:", "LOMEM:", "ONERR", "RESUME", "RECALL",
+	"STORE", "SPEED=", "LET", "GOTO", "RUN", "IF", "RESTORE", "&",
+	"GOSUB", "RETURN", "REM", "STOP", "ON", "WAIT", "LOAD", "SAVE",
+	"DEF", "POKE", "PRINT", "CONT", "LIST", "CLEAR", "GET", "NEW",
+	"TAB(", "TO", "FN", "SPC(", "THEN", "AT", "NOT", "STEP",
+	"+", "-", "*", "/", "^", "AND", "OR", ">",
+	"=", "<", "SGN", "INT", "ABS", "USR", "FRE", "SCRN(",
+	"PDL", "POS", "SQR", "RND", "LOG", "EXP", "COS", "SIN",
+	"TAN", "ATN", "PEEK", "LEN", "STR$", "VAL", "ASC", "CHR$",
+	"LEFT$", "RIGHT$", "MID$",
+}
+
+var integerTokens = [128]string{
+	"HIMEM:", "", "_", ":", "LOAD", "SAVE", "CON", "RUN",
+	"RUN", "DEL", ",", "NEW", "CLR", "AUTO", ",", "MAN",
+	"HIMEM:", "LOMEM:", "+", "-", "*", "/", "=", "#",
+	">=", ">", "<=", "<>", "<", "AND", "OR", "MOD",
+	"^", "+", "(", ",", "THEN", "THEN", ",", ",",
+	"\"", "\"", "(", "!", "!", "(", "PEEK", "RND",
+	"SGN", "ABS", "PDL", "RNDX", "(", "+", "-", "NOT",
+	"(", "=", "#", "LEN(", "ASC(", "SCRN(", ",", "(",
+	"$", "$", "(", ",", ",", ";", ";", ";",
+	",", ",", ",", "TEXT", "GR", "CALL", "DIM", "DIM",
+	"TAB", "END", "INPUT", "INPUT", "INPUT", "FOR", "=", "TO",
+	"STEP", "NEXT", ",", "RETURN", "GOSUB", "REM", "LET", "GOTO",
+	"IF", "PRINT", "PRINT", "PRINT", "POKE", ",", "COLOR=", "PLOT",
+	",", "HLIN", ",", "AT", "VLIN", ",", "AT", "VTAB",
+	"=", "=", ")", ")", "LIST", ",", "LIST", "POP",
+	"NODSP", "DSP", "NOTRACE", "DSP", "DSP", "TRACE", "PR#", "IN#",
+}
+
+const (
+	intEndOfLine  = 0x01
+	intOpenQuote  = 0x28
+	intCloseQuote = 0x29
+	intREM        = 0x5D
+)
+
+// Contents returns the data of file as DOS would load it, without the header
+// of BASIC and BINARY files, along with the load address of a BINARY file.
+func (dsk *Diskette) Contents(file FileEntry) (data []byte, address uint16, err error) {
+	data, err = dsk.ReadAll(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch file.Type() {
+	case ftBinary:
+		if len(data) < 4 {
+			return nil, 0, fmt.Errorf("%s: missing BINARY header", file.Name().PathSafe())
+		}
+		return data[4:], word(data), nil
+	case ftApplesoftBasic, ftIntegerBasic:
+		if len(data) < 2 {
+			return nil, 0, fmt.Errorf("%s: missing BASIC header", file.Name().PathSafe())
+		}
+		length := min(int(word(data)), len(data)-2)
+		return data[2:][:length], 0, nil
+	default:
+		return data, 0, nil
+	}
+}
+
+// Listing returns a readable version of file, as LIST would show a BASIC
+// program, or the text of a TEXT file. It reports false for other files.
+func (dsk *Diskette) Listing(file FileEntry) (string, bool, error) {
+	var list func([]byte) string
+	switch file.Type() {
+	case ftApplesoftBasic:
+		list = ApplesoftListing
+	case ftIntegerBasic:
+		list = IntegerListing
+	case ftText:
+		list = TextListing
+	default:
+		return "", false, nil
+	}
+	data, _, err := dsk.Contents(file)
+	if err != nil {
+		return "", false, err
+	}
+	return list(data), true, nil
+}
+
+// ApplesoftListing detokenizes an Applesoft BASIC program.
+func ApplesoftListing(program []byte) string {
+	var sb strings.Builder
+	for len(program) >= 4 && word(program) != 0 {
+		fmt.Fprintf(&sb, "%d ", word(program[2:]))
+		program = program[4:]
+		for len(program) > 0 && program[0] != 0 {
+			b := program[0]
+			program = program[1:]
+			if b < 0x80 {
+				sb.WriteByte(b)
+			} else if token := int(b) - 0x80; token < len(applesoftTokens) {
+				writeToken(&sb, applesoftTokens[token])
+			} else {
+				fmt.Fprintf(&sb, "{$%02X}", b)
+			}
+		}
+		sb.WriteByte('\n')
+		if len(program) > 0 {
+			program = program[1:]
+		}
+	}
+	return sb.String()
+}
+
+// IntegerListing detokenizes an Integer BASIC program.
+func IntegerListing(program []byte) string {
+	var sb strings.Builder
+	for len(program) >= 4 {
+		length := int(program[0])
+		if length < 4 || length > len(program) {
+			break
+		}
+		line := program[3:length]
+		fmt.Fprintf(&sb, "%d ", word(program[1:]))
+		program = program[length:]
+
+		inName := false // digits after a letter are part of a variable's name
+		for i := 0; i < len(line) && line[i] != intEndOfLine; i++ {
+			b := line[i]
+			switch {
+			case b == intREM:
+				sb.WriteString(" REM ")
+				for i++; i < len(line) && line[i] != intEndOfLine; i++ {
+					sb.WriteByte(line[i] & 0x7F)
+				}
+			case b == intOpenQuote:
+				sb.WriteByte('"')
+				for i++; i < len(line) && line[i] != intCloseQuote; i++ {
+					sb.WriteByte(line[i] & 0x7F)
+				}
+				sb.WriteByte('"')
+			case b >= 0xB0 && b <= 0xB9 && !inName:
+				if i+2 < len(line) {
+					fmt.Fprintf(&sb, "%d", word(line[i+1:]))
+				}
+				i += 2
+			case b >= 0x80:
+				sb.WriteByte(b & 0x7F)
+				inName = true
+				continue
+			default:
+				writeToken(&sb, integerTokens[b])
+			}
+			inName = false
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// writeToken writes a keyword with a space on either side, as LIST does, and
+// anything else, such as an operator, as it is.
+func writeToken(sb *strings.Builder, token string) {
+	if token != "" && token[0] >= 'A' && token[0] <= 'Z' {
+		sb.WriteString(" " + token + " ")
+	} else {
+		sb.WriteString(token)
+	}
+}
+
+// TextListing returns the text of a sequential TEXT file, which ends at the
+// first zero byte and has Hi-ASCII characters with CR between lines.
+func TextListing(data []byte) string {
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	text := make([]byte, len(data))
+	for i, b := range data {
+		text[i] = b & 0x7F
+		if text[i] == '\r' {
+			text[i] = '\n'
+		}
+	}
+	return string(text)
+}
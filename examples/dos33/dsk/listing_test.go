@@ -0,0 +1,55 @@
+package dsk
+
+import (
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestApplesoftListing(t *testing.T) {
+	program := []byte{
+		0x0C, 0x08, 10, 0, 0xBA, '"', 'H', 'I', '"', 0, // 10 PRINT "HI"
+		0x14, 0x08, 20, 0, 0xAB, '1', '0', 0, // 20 GOTO 10
+		0, 0,
+	}
+	want := "10  PRINT \"HI\"\n20  GOTO 10\n"
+	if got := ApplesoftListing(program); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestIntegerListing(t *testing.T) {
+	program := []byte{
+		9, 10, 0, 0x61, 0x28, 0xC8, 0xC9, 0x29, 0x01, // 10 PRINT "HI"
+		8, 20, 0, 0x5F, 0xB1, 10, 0, 0x01, // 20 GOTO 10
+		10, 30, 0, 0xC1, 0xB1, 0x71, 0xB5, 5, 0, 0x01, // 30 A1=5
+	}
+	want := "10  PRINT \"HI\"\n20  GOTO 10\n30 A1=5\n"
+	if got := IntegerListing(program); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestTextListing(t *testing.T) {
+	if got := TextListing([]byte{0xC8, 0xC9, 0x8D, 0xC1, 0x8D, 0, 0xFF}); got != "HI\nA\n" {
+		t.Fatalf("Expected the text up to the first zero, got %q", got)
+	}
+}
+
+func TestContents(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "PROG", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, []byte{0xA9, 0xC1, 0x60})},
+		dsktest.File{Name: "HELLO", Type: dsktest.ApplesoftBasic, Data: []byte{0x03, 0x00, 0x00, 0x00, 0x00}},
+	))
+
+	data, address, err := dsk.Contents(dsk.FindFile("PROG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != 0x0300 || string(data) != "\xA9\xC1\x60" {
+		t.Fatalf("Expected 3 bytes at $0300, got % X at $%04X", data, address)
+	}
+	if data, _, _ := dsk.Contents(dsk.FindFile("HELLO")); len(data) != 3 {
+		t.Fatal("Expected the 3-byte program, got", len(data), "bytes")
+	}
+}
@@ -0,0 +1,171 @@
+package dsk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+/// File Times
+/*
+DOS keeps no times, so every file on a disk has the disk's ModTime. To give
+files their own, the times of the changes made to them are kept in a JSON
+sidecar next to the image, DISK.dsk.meta, or next to its overlay if it has one:
+
+  {"baseline": "2024-05-01T09:30:00Z",
+   "files": [{"entry": 2, "name": "HELLO", "created": "2024-05-01T09:30:00Z",
+              "modified": "2024-05-27T12:00:00Z"}]}
+
+The baseline is the disk's ModTime when the sidecar was begun, and is the time
+of every file without a record of its own, as saving the image changes its
+ModTime however few files changed. Each record is for the entry at that index
+in the catalog, and only while the entry keeps its name (as a
+[Filename.PathName]) and stays deleted or not, so a record no longer applies
+once its file is replaced by another. Deleting, locking, unlocking or retyping
+a file changes its modified time, which is recorded once the image has been
+saved.
+
+A file is created when its entry first gets a record, at the time it had
+until then, and keeps that through its later changes, deletion included.
+Discarding an overlay discards the times of its changes too.
+*/
+
+// meta is the sidecar of file times of a Diskette.
+type meta struct {
+	path     string
+	Baseline time.Time   `json:"baseline"` // of files without a record
+	Files    []fileTimes `json:"files"`
+}
+
+// fileTimes are the times recorded for one catalog entry.
+type fileTimes struct {
+	Entry    int       `json:"entry"` // index in [Diskette.Catalog]
+	Name     string    `json:"name"`  // as [Filename.PathName] gives it
+	Deleted  bool      `json:"deleted,omitempty"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// UseFileTimes keeps the time of each change to a file in a sidecar, reading
+// the times already there. See [Diskette.FileModTime]. A disk whose changes
+// cannot be saved has no sidecar.
+func (dsk *Diskette) UseFileTimes() error {
+	var path string
+	switch {
+	case dsk.overlay != nil:
+		path = strings.TrimSuffix(dsk.overlay.path, ".overlay") + ".meta"
+	case dsk.writable():
+		path = dsk.path + ".meta"
+	default:
+		return nil
+	}
+
+	m := &meta{path: path}
+	buf, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(buf, m); err != nil {
+			return fmt.Errorf("file times %s: %w", path, err)
+		}
+	}
+	if m.Baseline.IsZero() {
+		m.Baseline = dsk.ModTime()
+	}
+	dsk.meta = m
+	return nil
+}
+
+// FileModTime returns when file was last changed, or the sidecar's baseline if
+// no change to it was recorded. Without file times, it is the disk's ModTime.
+func (dsk *Diskette) FileModTime(file FileEntry) time.Time {
+	if dsk.meta == nil {
+		return dsk.ModTime()
+	}
+	if times, ok := dsk.meta.find(dsk, file); ok {
+		return times.Modified
+	}
+	return dsk.meta.Baseline
+}
+
+// FileCreated returns when file was created, as far as the sidecar knows, or
+// false without file times.
+func (dsk *Diskette) FileCreated(file FileEntry) (time.Time, bool) {
+	if dsk.meta == nil {
+		return time.Time{}, false
+	}
+	if times, ok := dsk.meta.find(dsk, file); ok && !times.Created.IsZero() {
+		return times.Created, true
+	}
+	return dsk.meta.Baseline, true
+}
+
+// find returns the times recorded for file.
+func (m *meta) find(dsk *Diskette, file FileEntry) (*fileTimes, bool) {
+	entry := dsk.entryIndex(file)
+	for i := range m.Files {
+		times := m.Files[i]
+		if times.Entry == entry && times.Name == file.Name().PathName() && times.Deleted == file.IsDeleted() {
+			return &m.Files[i], true
+		}
+	}
+	return nil, false
+}
+
+// stamp records that the changed files were modified at now. A file keeps the
+// time it was created at from the record of its entry under the same name.
+func (m *meta) stamp(dsk *Diskette, now time.Time, changed ...FileEntry) {
+	for _, file := range changed {
+		entry, name := dsk.entryIndex(file), file.Name().PathName()
+		created := m.Baseline
+		for _, times := range m.Files {
+			if times.Entry == entry && times.Name == name && !times.Created.IsZero() {
+				created = times.Created
+			}
+		}
+		// This replaces any record of the entry, even one of a file that is gone.
+		m.Files = slices.DeleteFunc(m.Files, func(times fileTimes) bool { return times.Entry == entry })
+		m.Files = append(m.Files, fileTimes{Entry: entry, Name: name, Deleted: file.IsDeleted(), Created: created, Modified: now})
+	}
+}
+
+// reset forgets every record, as when the changes they are of are discarded,
+// and begins again from the disk's ModTime.
+func (m *meta) reset(dsk *Diskette) error {
+	m.Files = nil
+	m.Baseline = dsk.ModTime()
+	if err := os.Remove(m.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// write rewrites the sidecar with the records.
+func (m *meta) write() error {
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so a crash never leaves a partial sidecar behind.
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.path)
+}
+
+// entryIndex returns the index of file in the catalog, or -1.
+func (dsk *Diskette) entryIndex(file FileEntry) int {
+	for i, entry := range dsk.Catalog() {
+		if &entry[0] == &file[0] {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,101 @@
+package dsk
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestFileTimes(t *testing.T) {
+	path := dsktest.Write(t, t.TempDir(), "DISK.dsk",
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte("HI")},
+		dsktest.File{Name: "OTHER", Type: dsktest.Text, Data: []byte("BYE")},
+	)
+	load := func() *Diskette {
+		dsk, err := LoadDiskette(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dsk.UseFileTimes(); err != nil {
+			t.Fatal(err)
+		}
+		return dsk
+	}
+
+	dsk := load()
+	if _, err := os.Stat(path + ".meta"); !os.IsNotExist(err) {
+		t.Fatal("Expected no sidecar until a file changes:", err)
+	}
+	baseline := dsk.ModTime()
+	before := time.Now().Add(-time.Second)
+	if err := dsk.Lock(dsk.FindFile("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+
+	dsk = load()
+	if modTime := dsk.FileModTime(dsk.FindFile("HELLO")); modTime.Before(before) {
+		t.Errorf("Expected HELLO to have been modified just now, not %v", modTime)
+	}
+	if modTime := dsk.FileModTime(dsk.FindFile("OTHER")); !modTime.Equal(baseline) {
+		t.Errorf("Expected OTHER to keep the disk's time from before the change, %v, not %v", baseline, modTime)
+	}
+	if created, ok := dsk.FileCreated(dsk.FindFile("HELLO")); !ok || !created.Equal(baseline) {
+		t.Errorf("Expected HELLO to have been created by %v, not %v", baseline, created)
+	}
+
+	// Once deleted, HELLO is another file, whose time replaces the first's.
+	if err := dsk.Unlock(dsk.FindFile("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dsk.Delete(dsk.FindFile("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+	if len(dsk.meta.Files) != 1 || !dsk.meta.Files[0].Deleted || !dsk.meta.Files[0].Created.Equal(baseline) {
+		t.Errorf("Expected only the deleted entry's times, created as before, got %+v", dsk.meta.Files)
+	}
+	if modTime := dsk.FileModTime(dsk.FindFile("OTHER")); !modTime.Equal(baseline) {
+		t.Errorf("Expected OTHER to keep its time through other changes, not %v", modTime)
+	}
+
+	// A change that is not saved records no time.
+	dsk.hostFile.Close()
+	if err := dsk.Lock(dsk.FindFile("OTHER")); err == nil {
+		t.Fatal("Expected the lock to fail with the image closed")
+	}
+	if _, ok := dsk.meta.find(dsk, dsk.FindFile("OTHER")); ok {
+		t.Errorf("Expected no time for a change that was not saved, got %+v", dsk.meta.Files)
+	}
+}
+
+func TestFileTimesDiscarded(t *testing.T) {
+	dir := t.TempDir()
+	path := dsktest.Write(t, dir, "DISK.dsk", dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte("HI")})
+	dsk, err := LoadDisketteOverlay(path, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dsk.UseFileTimes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dsk.Lock(dsk.FindFile("HELLO")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dsk.meta.find(dsk, dsk.FindFile("HELLO")); !ok {
+		t.Fatal("Expected a time for the lock")
+	}
+
+	if err := dsk.Discard(); err != nil {
+		t.Fatal(err)
+	}
+	if len(dsk.meta.Files) != 0 {
+		t.Errorf("Expected the times of discarded changes to go, got %+v", dsk.meta.Files)
+	}
+	if _, err := os.Stat(dsk.meta.path); !os.IsNotExist(err) {
+		t.Error("Expected the sidecar to be removed:", err)
+	}
+	if modTime := dsk.FileModTime(dsk.FindFile("HELLO")); !modTime.Equal(dsk.ModTime()) {
+		t.Errorf("Expected HELLO to have the disk's time again, not %v", modTime)
+	}
+}
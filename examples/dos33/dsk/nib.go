@@ -0,0 +1,75 @@
+package dsk
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+)
+
+/// Nibble Images
+/*
+http://fileformats.archiveteam.org/wiki/NIB
+
+A .nib file holds the disk bytes of each track, as the Disk II controller
+would read them, without the timing of the self-sync bytes. Every track is
+6656 nibbles long, so a 35-track disk is 232960 bytes.
+*/
+
+const nibTrackSize = 6656
+
+// nibImage reads and writes .nib files.
+//
+// Tracks whose sectors have not changed are written back exactly as they were
+// read, so only modified tracks lose any nonstandard formatting.
+type nibImage struct {
+	file            []byte // the .nib file as last read or written
+	sectors         []byte // the sectors held by file
+	sectorsPerTrack int
+	volume          byte
+}
+
+func (nib *nibImage) decode(file []byte) ([]byte, error) {
+	if len(file) == 0 || len(file)%nibTrackSize != 0 {
+		return nil, fmt.Errorf("nib: %d bytes is not a whole number of %d-nibble tracks", len(file), nibTrackSize)
+	}
+
+	tracks := make([][]byte, len(file)/nibTrackSize)
+	for t := range tracks {
+		track := file[t*nibTrackSize:][:nibTrackSize]
+		tracks[t] = append(slices.Clone(track), track...)
+	}
+	nib.sectorsPerTrack = sectorsPerNibbleTrack(tracks)
+
+	image, volume := decodeNibbleTracks(tracks, nib.sectorsPerTrack)
+	if volume == 0 {
+		volume = 254
+	}
+	nib.file, nib.sectors, nib.volume = file, slices.Clone(image), volume
+	return image, nil
+}
+
+func (nib *nibImage) encode(sectors []byte) ([]byte, error) {
+	if nib.sectorsPerTrack != 13 && nib.sectorsPerTrack != 16 {
+		return nil, fmt.Errorf("nib: cannot encode %d sectors per track", nib.sectorsPerTrack)
+	}
+
+	const sectorSize = 256
+	trackBytes := nib.sectorsPerTrack * sectorSize
+	tracks := len(sectors) / trackBytes
+
+	file := make([]byte, 0, tracks*nibTrackSize)
+	for t := 0; t < tracks; t++ {
+		track := sectors[t*trackBytes:][:trackBytes]
+		unchanged := (t+1)*trackBytes <= len(nib.sectors) && (t+1)*nibTrackSize <= len(nib.file) &&
+			bytes.Equal(track, nib.sectors[t*trackBytes:][:trackBytes])
+		if unchanged {
+			file = append(file, nib.file[t*nibTrackSize:][:nibTrackSize]...)
+			continue
+		}
+		file = append(file, encodeNibbleTrack(nib.volume, byte(t), nib.sectorsPerTrack, nibTrackSize,
+			func(position int) []byte { return track[position*sectorSize:][:sectorSize] })...)
+	}
+
+	nib.file, nib.sectors = file, slices.Clone(sectors)
+	return file, nil
+}
@@ -0,0 +1,128 @@
+package dsk
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+/// Sector Order
+/*
+http://fileformats.archiveteam.org/wiki/DSK_(Apple_II)
+
+Sector images store each track's sectors one after another, but not always in
+the same order. DOS-ordered images (.dsk, .do) store them in DOS 3.3 logical
+order, while ProDOS-ordered images (.po) store them in ProDOS block order, so
+each pair of 256-byte halves makes up one 512-byte block.
+
+The same physical sector therefore ends up at a different position within the
+track:
+
+DOS logical sector   0 1 2 3 4 5 6 7 8 9 A B C D E F
+ProDOS-order offset  0 E D C B A 9 8 7 6 5 4 3 2 1 F
+
+Only 16-sector tracks have a ProDOS order.
+*/
+
+// SectorOrder is the order in which an image stores the sectors of a track.
+type SectorOrder uint8
+
+const (
+	DOSOrder    SectorOrder = iota // .dsk and .do images
+	ProDOSOrder                    // .po images
+)
+
+var prodosPositions = [16]uint{0x0, 0xE, 0xD, 0xC, 0xB, 0xA, 0x9, 0x8, 0x7, 0x6, 0x5, 0x4, 0x3, 0x2, 0x1, 0xF}
+
+func (order SectorOrder) String() string {
+	return map[SectorOrder]string{
+		DOSOrder:    "DOS",
+		ProDOSOrder: "ProDOS",
+	}[order]
+}
+
+// position returns where in the track the image stores a DOS logical sector.
+func (order SectorOrder) position(sector, sectorsPerTrack uint) uint {
+	if order == ProDOSOrder && sectorsPerTrack == 16 {
+		return prodosPositions[sector]
+	}
+	return sector
+}
+
+// sector returns the DOS logical sector stored at a position in the track.
+// It is the inverse of position.
+func (order SectorOrder) sector(position, sectorsPerTrack uint) uint {
+	if order == ProDOSOrder && sectorsPerTrack == 16 {
+		for sector, pos := range prodosPositions {
+			if pos == position {
+				return uint(sector)
+			}
+		}
+	}
+	return position
+}
+
+// orderByExt returns the sector order implied by the extension of path.
+func orderByExt(path string) SectorOrder {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".po":
+		return ProDOSOrder
+	default:
+		return DOSOrder
+	}
+}
+
+// detectOrder returns the sector order of dsk, which is the one whose
+// catalog looks most like a DOS 3.3 catalog. Ties go to dsk's current order,
+// which is usually the one implied by the file's extension.
+func (dsk *Diskette) detectOrder() SectorOrder {
+	guess := dsk.order
+	best, bestScore := guess, dsk.probeCatalog()
+	for _, order := range []SectorOrder{DOSOrder, ProDOSOrder} {
+		if order == guess {
+			continue
+		}
+		dsk.order = order
+		if score := dsk.probeCatalog(); score > bestScore {
+			best, bestScore = order, score
+		}
+	}
+	dsk.order = guess
+	return best
+}
+
+// probeCatalog scores how sane the catalog is when read with dsk's current
+// sector order. Every well-linked catalog sector and every plausible file
+// entry adds a point.
+//
+// Reading with the wrong order usually breaks the catalog's chain of
+// sectors after its first link, since DOS INIT links them in descending order
+// on track 17.
+func (dsk *Diskette) probeCatalog() (score int) {
+	entryOffsets := []uint8{0x0B, 0x2E, 0x51, 0x74, 0x97, 0xBA, 0xDD}
+	inRange := func(t, s uint) bool { return t < dsk.NumTracks() && s < dsk.SectorsPerTrack() }
+
+	seen := make(map[[2]uint]bool)
+	t, s := uint(dsk.vtoc[0x01]), uint(dsk.vtoc[0x02])
+	for t != 0 && inRange(t, s) && !seen[[2]uint{t, s}] {
+		seen[[2]uint{t, s}] = true
+		catalog := dsk.rawSector(t, s)
+		nextT, nextS := uint(catalog[0x01]), uint(catalog[0x02])
+		if nextT == 0 || inRange(nextT, nextS) {
+			score++
+		}
+		for _, offset := range entryOffsets {
+			entry := FileEntry(catalog[offset:])
+			if entry.IsEmpty() || entry.IsDeleted() {
+				continue
+			}
+			if tsT, tsS := entry.FirstTSList(); inRange(tsT, tsS) && entry.Type().valid() {
+				score++
+			}
+		}
+		t, s = nextT, nextS
+	}
+	return
+}
+
+// Order returns the sector order of the disk image.
+func (dsk *Diskette) Order() SectorOrder { return dsk.order }
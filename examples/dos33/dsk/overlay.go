@@ -0,0 +1,194 @@
+package dsk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+/// Copy-on-write Overlay
+/*
+An overlay keeps the base disk image pristine by storing every sector that
+differs from it in a sidecar file instead. The served Diskette is the base
+image with the overlay applied.
+
+offset
+----
+$00-07 magic "DSKOVRLY"
+$08-0B size of the base image in bytes (LO/HI format)
+$0C-   records, one per modified sector:
+       $00-03 index of the sector in the image (LO/HI format)
+       $04-   the sector's contents
+*/
+
+const overlayMagic = "DSKOVRLY"
+
+type overlay struct {
+	path string
+	base []byte // contents of the base image on the host
+}
+
+// LoadDisketteOverlay reads the disk image at path like [LoadDiskette], but
+// changes are stored in a sidecar overlay file in dir rather than in the image.
+// An existing overlay for the image is applied.
+func LoadDisketteOverlay(path, dir string) (*Diskette, error) {
+	dsk, err := LoadDiskette(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := dsk.useOverlay(filepath.Join(dir, filepath.Base(path)+".overlay")); err != nil {
+		return nil, err
+	}
+	return dsk, nil
+}
+
+// useOverlay stores changes to dsk in the overlay file at path, applying any
+// changes already there.
+func (dsk *Diskette) useOverlay(path string) error {
+	ovl := &overlay{
+		path: path,
+		base: slices.Clone(dsk.bytes),
+	}
+	if err := ovl.apply(dsk.bytes, int(dsk.SectorSize())); err != nil {
+		return err
+	}
+	dsk.overlay = ovl
+	return nil
+}
+
+// HasOverlay reports whether changes to dsk are stored in an overlay.
+func (dsk *Diskette) HasOverlay() bool { return dsk.overlay != nil }
+
+// OverlaySectors returns the sectors that differ from the base image, as
+// track/sector pairs.
+func (dsk *Diskette) OverlaySectors() (sectors [][2]uint) {
+	if dsk.overlay == nil {
+		return nil
+	}
+	size := int(dsk.SectorSize())
+	for _, index := range dsk.overlay.changed(dsk.bytes, size) {
+		track, position := uint(index)/dsk.SectorsPerTrack(), uint(index)%dsk.SectorsPerTrack()
+		sectors = append(sectors, [2]uint{track, dsk.order.sector(position, dsk.SectorsPerTrack())})
+	}
+	return
+}
+
+// Discard throws away every change in the overlay, restoring the base image.
+func (dsk *Diskette) Discard() error {
+	if dsk.overlay == nil {
+		return errors.ErrUnsupported
+	}
+	if err := dsk.overlay.remove(); err != nil {
+		return err
+	}
+	copy(dsk.bytes, dsk.overlay.base)
+	dsk.generation.Add(1)
+	if dsk.meta != nil {
+		return dsk.meta.reset(dsk)
+	}
+	return nil
+}
+
+// Commit writes the changes in the overlay into the base image, then removes
+// the overlay.
+func (dsk *Diskette) Commit() error {
+	if dsk.overlay == nil {
+		return errors.ErrUnsupported
+	}
+	if dsk.readonly {
+		return dsk.errReadonly()
+	}
+	if err := dsk.writeHost(); err != nil {
+		return err
+	}
+	copy(dsk.overlay.base, dsk.bytes)
+	dsk.generation.Add(1)
+	return dsk.overlay.remove()
+}
+
+// apply reads the overlay file, if any, onto image.
+func (ovl *overlay) apply(image []byte, sectorSize int) error {
+	buf, err := os.ReadFile(ovl.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	const headerSize = len(overlayMagic) + 4
+	if len(buf) < headerSize || string(buf[:len(overlayMagic)]) != overlayMagic {
+		return fmt.Errorf("overlay %s: not an overlay file", ovl.path)
+	}
+	if size := binary.LittleEndian.Uint32(buf[len(overlayMagic):]); int(size) != len(image) {
+		return fmt.Errorf("overlay %s: made for a %d byte image, not %d bytes", ovl.path, size, len(image))
+	}
+
+	records := buf[headerSize:]
+	recordSize := 4 + sectorSize
+	if len(records)%recordSize != 0 {
+		return fmt.Errorf("overlay %s: truncated", ovl.path)
+	}
+	for ; len(records) > 0; records = records[recordSize:] {
+		index := int(binary.LittleEndian.Uint32(records))
+		if (index+1)*sectorSize > len(image) {
+			return fmt.Errorf("overlay %s: sector %d is out of range", ovl.path, index)
+		}
+		copy(image[index*sectorSize:], records[4:recordSize])
+	}
+	return nil
+}
+
+// save rewrites the overlay file with every sector of image that differs from
+// the base.
+func (ovl *overlay) save(image []byte, sectorSize int) error {
+	changed := ovl.changed(image, sectorSize)
+	if len(changed) == 0 {
+		return ovl.remove()
+	}
+
+	buf := bytes.NewBufferString(overlayMagic)
+	binary.Write(buf, binary.LittleEndian, uint32(len(image)))
+	for _, index := range changed {
+		binary.Write(buf, binary.LittleEndian, uint32(index))
+		buf.Write(image[index*sectorSize:][:sectorSize])
+	}
+
+	// Write then rename so a crash never leaves a partial overlay behind.
+	tmp := ovl.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ovl.path)
+}
+
+// changed returns the indexes of the sectors of image that differ from the base.
+func (ovl *overlay) changed(image []byte, sectorSize int) (indexes []int) {
+	for i := 0; i*sectorSize < len(image); i++ {
+		offset := i * sectorSize
+		if !bytes.Equal(image[offset:][:sectorSize], ovl.base[offset:][:sectorSize]) {
+			indexes = append(indexes, i)
+		}
+	}
+	return
+}
+
+func (ovl *overlay) remove() error {
+	if err := os.Remove(ovl.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (ovl *overlay) modTime() (time.Time, bool) {
+	fi, err := os.Stat(ovl.path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return fi.ModTime(), true
+}
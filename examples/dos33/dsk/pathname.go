@@ -0,0 +1,167 @@
+package dsk
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+/// Path Names
+/*
+A Filename can hold any byte, but not every byte is usable in a path, and
+[Filename.PathSafe] maps inverse and normal letters to the same name. PathName
+encodes each byte of a Filename as its own distinct character, so the encoding
+is reversible and no two Filenames share a path name:
+
+  normal characters     as themselves, except for the few below
+  inverse characters    followed by U+20DE COMBINING ENCLOSING SQUARE: H⃞
+  flashing characters   followed by U+20DF COMBINING ENCLOSING DIAMOND: H⃟
+  control characters    as Control Pictures, U+2400 to U+241F: ␇
+  DEL ($FF)             as U+2421 SYMBOL FOR DELETE: ␡
+
+  /  as U+2215 DIVISION SLASH, which can appear in a path
+  ,  as U+201A SINGLE LOW-9 QUOTATION MARK, so no name ends in ",locked"
+  _  as U+FF3F FULLWIDTH LOW LINE, so no name starts like a deleted file's
+  ~  as U+223C TILDE OPERATOR, so no name ends like a repeated one's
+
+A name that is all dots uses U+2024 ONE DOT LEADER for them instead, as "."
+and ".." mean something else in a path.
+
+Normal DOS names, such as "HELLO" or "COPY.OBJ", are unchanged.
+*/
+
+const (
+	markInverse  = '⃞'
+	markFlashing = '⃟'
+	controlBase  = '␀'
+	symbolDelete = '␡'
+	dotLeader    = '․'
+)
+
+// escapedChars maps the ASCII characters that cannot stand for themselves in
+// a path name to the characters used instead.
+var escapedChars = map[byte]rune{
+	'/': '∕',
+	',': '‚',
+	'_': '＿',
+	'~': '∼',
+}
+
+// PathName returns the reversible, collision-free encoding of name for use in
+// paths. See [ParsePathName] to decode it.
+func (name Filename) PathName() string {
+	var sb strings.Builder
+	char := func(c byte) {
+		if r, ok := escapedChars[c]; ok {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte(c)
+		}
+	}
+	for _, b := range name {
+		switch {
+		case b == 0xFF:
+			sb.WriteRune(symbolDelete)
+		case b >= 0x80 && b < 0xA0:
+			sb.WriteRune(controlBase + rune(b-0x80))
+		case b >= 0xA0:
+			char(b - 0x80)
+		case b < 0x40: // inverse: @ to _, then space to ?
+			char(displayChar(b))
+			sb.WriteRune(markInverse)
+		default: // flashing: @ to _, then space to ?
+			char(displayChar(b - 0x40))
+			sb.WriteRune(markFlashing)
+		}
+	}
+	if encoded := sb.String(); encoded == "." || encoded == ".." {
+		return strings.Repeat(string(dotLeader), len(encoded))
+	}
+	return sb.String()
+}
+
+// displayChar returns the ASCII character shown for b, from $00 to $3F, in
+// inverse or flashing.
+func displayChar(b byte) byte {
+	if b < 0x20 {
+		return b + 0x40
+	}
+	return b
+}
+
+// ParsePathName decodes a name made by [Filename.PathName]. It is an error if
+// s is not exactly such a name.
+func ParsePathName(s string) (Filename, error) {
+	var name Filename
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+
+		var c byte
+		switch {
+		case r == symbolDelete:
+			name = append(name, 0xFF)
+			continue
+		case r >= controlBase && r < controlBase+0x20:
+			name = append(name, byte(r-controlBase)+0x80)
+			continue
+		case r == dotLeader:
+			c = '.'
+		case r >= 0x20 && r < 0x7F:
+			if _, escaped := escapedChars[byte(r)]; escaped {
+				return nil, fmt.Errorf("path name %q: %q must be escaped", s, r)
+			}
+			c = byte(r)
+		default:
+			if c = unescapeChar(r); c == 0 {
+				return nil, fmt.Errorf("path name %q: %q is not part of a DOS name", s, r)
+			}
+		}
+
+		mark, markSize := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case mark == markInverse && c >= 0x20 && c < 0x60:
+			name = append(name, c&0x3F)
+			i += markSize
+		case mark == markFlashing && c >= 0x20 && c < 0x60:
+			name = append(name, c&0x3F|0x40)
+			i += markSize
+		default:
+			name = append(name, c|0x80)
+		}
+	}
+	if name.PathName() != s {
+		return nil, fmt.Errorf("path name %q is not in its canonical form", s)
+	}
+	return name, nil
+}
+
+// unescapeChar returns the ASCII character that r stands for in a path name,
+// or zero.
+func unescapeChar(r rune) byte {
+	for c, escaped := range escapedChars {
+		if escaped == r {
+			return c
+		}
+	}
+	return 0
+}
+
+// PathNames returns a unique name for each entry of the catalog, in order, for
+// use in paths: the entry's [Filename.PathName], followed by "~2", "~3" and so
+// on when an earlier entry has the same name. Deleted entries are numbered
+// apart from the others.
+func (dsk *Diskette) PathNames() []string {
+	seen := map[bool]map[string]int{false: {}, true: {}}
+	var names []string
+	for _, entry := range dsk.Catalog() {
+		name := entry.Name().PathName()
+		count := seen[entry.IsDeleted()]
+		count[name]++
+		if n := count[name]; n > 1 {
+			name = fmt.Sprintf("%s~%d", name, n)
+		}
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,83 @@
+package dsk
+
+import (
+	"bytes"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestPathNameRoundTrips(t *testing.T) {
+	seen := make(map[string]byte)
+	for b := 0; b < 256; b++ {
+		name := Filename{byte(b)}
+		encoded := name.PathName()
+		if other, ok := seen[encoded]; ok {
+			t.Fatalf("$%02X and $%02X are both %q", other, b, encoded)
+		}
+		seen[encoded] = byte(b)
+
+		decoded, err := ParsePathName(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded, name) {
+			t.Fatalf("$%02X became %q and then % X", b, encoded, decoded)
+		}
+	}
+
+	for _, name := range []string{"HELLO", "COPY.OBJ", "APPLE ][ 4.0"} {
+		if got := hiASCII(name).PathName(); got != name {
+			t.Errorf("Expected %q to be unchanged, got %q", name, got)
+		}
+	}
+	for _, name := range []string{".", "..", "A/B", "X,locked", "_X.garbage", "X~2"} {
+		if got := hiASCII(name).PathName(); got == name {
+			t.Errorf("Expected %q to be escaped", name)
+		}
+	}
+}
+
+func TestParsePathNameRejectsOtherNames(t *testing.T) {
+	for _, s := range []string{"A/B", "X,locked", "⃞H", "é", "HELLO~2"} {
+		if name, err := ParsePathName(s); err == nil {
+			t.Errorf("Expected %q to be rejected, got % X", s, name)
+		}
+	}
+}
+
+func TestPathNamesAreUnique(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte{0}},
+		dsktest.File{RawName: []byte{0x08, 0x05, 0x0C, 0x0C, 0x0F}, Type: dsktest.Text, Data: []byte{0}},
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: []byte{0}},
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Deleted: true, Data: []byte{0}},
+	))
+
+	names := dsk.PathNames()
+	want := []string{"HELLO", "H⃞E⃞L⃞L⃞O⃞", "HELLO~2", "HELLO"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected %q, got %q", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected %q, got %q", want, names)
+		}
+	}
+
+	catalog := dsk.Catalog()
+	for i, name := range names[:3] {
+		if file := dsk.FindFile(name); file == nil || file.Name().ANSIEscaped() != catalog[i].Name().ANSIEscaped() {
+			t.Errorf("Expected %q to find entry %d", name, i)
+		}
+	}
+}
+
+// hiASCII returns s as a Filename of normal characters.
+func hiASCII(s string) Filename {
+	name := Filename(s)
+	for i := range name {
+		name[i] |= 0x80
+	}
+	return name
+}
@@ -0,0 +1,100 @@
+package dsk
+
+import (
+	"fmt"
+	"io"
+)
+
+// FileReader reads the contents of a file on the disk, as [Diskette.ReadAll]
+// returns them, straight from its sectors. Only the file's T/S lists are read
+// when it is opened; each data sector is read when a read reaches it.
+type FileReader struct {
+	dsk     *Diskette
+	sectors [][2]uint // track and sector of each data sector, in order
+	size    int64
+}
+
+// Open returns a reader of the contents of file.
+func (dsk *Diskette) Open(file FileEntry) (*FileReader, error) {
+	if !file.Type().valid() {
+		return nil, fmt.Errorf("%s: %w", file.Name().PathSafe(), errCorruptEntry)
+	}
+	r := &FileReader{dsk: dsk}
+	if err := r.findSectors(file); err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Name().PathSafe(), err)
+	}
+
+	sectorSize := int64(dsk.SectorSize())
+	r.size = int64(len(r.sectors)) * sectorSize
+	switch file.Type() {
+	case ftBinary, ftRelocatable:
+		// The first sector starts with a 4-byte header (address + length), and
+		// the rest of the last sector is not part of the file.
+		if len(r.sectors) > 0 {
+			header := r.sector(0)
+			r.size = min(r.size, 4+int64(word(header[0x02:])))
+		}
+	}
+	return r, nil
+}
+
+// findSectors follows the T/S lists of file to find its data sectors. Like
+// DOS, it stops reading a T/S list at its first empty entry.
+func (r *FileReader) findSectors(file FileEntry) error {
+	dsk := r.dsk
+	maxLists := dsk.NumTracks() * dsk.SectorsPerTrack() // more would mean a loop
+	t, s := file.FirstTSList()
+	for lists := uint(0); t != 0; lists++ {
+		if !dsk.validSector(t, s) || lists == maxLists {
+			return errCorruptEntry
+		}
+		list := tsList(dsk.rawSector(t, s))
+		for _, offset := range list.DataSectorOffsets() {
+			dt, ds := list.DataSectorTS(offset)
+			if dt == 0 {
+				// TODO: handle case of a non-sequential ("random") file that can have
+				// non-allocated data sectors. See "Beneath Apple DOS" Chapter 4.
+				break
+			}
+			if !dsk.validSector(dt, ds) {
+				return errCorruptEntry
+			}
+			r.sectors = append(r.sectors, [2]uint{dt, ds})
+		}
+		t, s = list.NextTSList()
+	}
+	return nil
+}
+
+// Size returns the length of the contents.
+func (r *FileReader) Size() int64 { return r.size }
+
+// ReadAt implements [io.ReaderAt], reading only the sectors that hold the
+// bytes asked for.
+func (r *FileReader) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("FileReader.ReadAt: negative offset %d", off)
+	}
+	sectorSize := int64(r.dsk.SectorSize())
+	for n < len(p) && off < r.size {
+		data := r.sector(int(off / sectorSize))[off%sectorSize:]
+		data = data[:min(int64(len(data)), r.size-off)]
+		copied := copy(p[n:], data)
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// sector returns the i'th data sector.
+func (r *FileReader) sector(i int) []byte {
+	return r.dsk.rawSector(r.sectors[i][0], r.sectors[i][1])
+}
+
+// validSector reports whether a track and sector are on the disk.
+func (dsk *Diskette) validSector(track, sector uint) bool {
+	return track < dsk.NumTracks() && sector < dsk.SectorsPerTrack()
+}
@@ -0,0 +1,70 @@
+package dsk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestFileReader(t *testing.T) {
+	text := make([]byte, 3*dsktest.SectorSize)
+	for i := range text {
+		text[i] = byte(i%200 + 1)
+	}
+	code := bytes.Repeat([]byte{0xEA}, 300)
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "TEXT", Type: dsktest.Text, Data: text},
+		dsktest.File{Name: "CODE", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0800, code)},
+	))
+
+	r, err := dsk.Open(dsk.FindFile("TEXT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(text)) {
+		t.Fatalf("Expected %d bytes, got %d", len(text), r.Size())
+	}
+	for _, off := range []int64{0, 1, 255, 256, 300, 700} {
+		buf := make([]byte, 40)
+		n, err := r.ReadAt(buf, off)
+		want := text[off:min(off+40, int64(len(text)))]
+		if !bytes.Equal(buf[:n], want) {
+			t.Fatalf("ReadAt %d: expected % X, got % X", off, want, buf[:n])
+		}
+		if len(want) < len(buf) && err != io.EOF {
+			t.Fatalf("ReadAt %d: expected io.EOF, got %v", off, err)
+		} else if len(want) == len(buf) && err != nil {
+			t.Fatalf("ReadAt %d: %v", off, err)
+		}
+	}
+
+	r, err = dsk.Open(dsk.FindFile("CODE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != 4+int64(len(code)) {
+		t.Fatalf("Expected a 4-byte header and %d bytes, got %d", len(code), r.Size())
+	}
+	if all, _ := dsk.ReadAll(dsk.FindFile("CODE")); !bytes.Equal(all, dsktest.BinaryData(0x0800, code)) {
+		t.Fatal("Expected ReadAll to return the header and code")
+	}
+}
+
+func TestFileReaderCorruptTSList(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "TEXT", Type: dsktest.Text, Data: []byte{0xC1, 0}},
+	))
+	file := dsk.FindFile("TEXT")
+	track, sector := file.FirstTSList()
+	tsList(dsk.rawSector(track, sector))[0x0C] = 0x7F // a track past the end
+
+	if _, err := dsk.Open(file); !errors.Is(err, errCorruptEntry) {
+		t.Fatal("Expected a corrupt entry, got", err)
+	}
+	if _, err := dsk.ReadAll(file); err == nil {
+		t.Fatal("Expected ReadAll to fail rather than panic")
+	}
+}
@@ -0,0 +1,110 @@
+package dsk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/// Searching
+/*
+A Query finds files by their names or contents. Text is matched regardless of
+case against:
+
+  name     the file's path name (see [Diskette.PathNames]) and its plain name
+  listing  the listing of a BASIC or TEXT file (see [Diskette.Listing])
+  bytes    the contents of any other file, read as ASCII or high ASCII
+
+A query starting with $ is hex instead, such as "$A9C1" or "$20 ED FD", and
+matches the exact bytes of the contents of any file.
+*/
+
+// maxHitsPerFile limits how many matches are reported in each file.
+const maxHitsPerFile = 100
+
+// Query is what [Search] looks for.
+type Query struct {
+	text  string // uppercase, if a text query
+	bytes []byte // if a hex query
+}
+
+// Hit is a match of a Query in a file.
+type Hit struct {
+	File   FileEntry
+	Name   string // as in paths, see [Diskette.PathNames]
+	Where  string // "name", "listing" or "bytes"
+	Offset int    // into the name, listing or contents
+}
+
+// ParseQuery returns the query that s describes.
+func ParseQuery(s string) (Query, error) {
+	if digits, ok := strings.CutPrefix(s, "$"); ok {
+		b, err := hex.DecodeString(strings.ReplaceAll(digits, " ", ""))
+		if err != nil || len(b) == 0 {
+			return Query{}, fmt.Errorf("query %q: not a run of hex bytes", s)
+		}
+		return Query{bytes: b}, nil
+	}
+	if strings.TrimSpace(s) == "" {
+		return Query{}, errors.New("empty query")
+	}
+	return Query{text: strings.ToUpper(s)}, nil
+}
+
+// Search returns the hits of q in the files on dsk, in catalog order. Deleted
+// files are left out. If the catalog is damaged, only the files before the
+// damage are searched, and the error says where it is.
+func Search(dsk *Diskette, q Query) ([]Hit, error) {
+	var hits []Hit
+	names := dsk.PathNames()
+	catalog, err := dsk.ReadCatalog()
+	for i, file := range catalog {
+		if file.IsDeleted() {
+			continue
+		}
+		add := func(where string, offsets []int) {
+			for _, offset := range offsets {
+				hits = append(hits, Hit{File: file, Name: names[i], Where: where, Offset: offset})
+			}
+		}
+
+		data, err := dsk.ReadAll(file)
+		if q.bytes != nil {
+			if err == nil {
+				add("bytes", indexes(data, q.bytes))
+			}
+			continue
+		}
+
+		add("name", indexes([]byte(strings.ToUpper(names[i])), []byte(q.text)))
+		if plain := file.Name().String(); plain != names[i] {
+			add("name", indexes([]byte(strings.ToUpper(plain)), []byte(q.text)))
+		}
+		if listing, ok, err := dsk.Listing(file); ok && err == nil {
+			add("listing", indexes([]byte(strings.ToUpper(listing)), []byte(q.text)))
+		} else if err == nil {
+			ascii := make([]byte, len(data))
+			for j, b := range data {
+				ascii[j] = b & 0x7F
+			}
+			add("bytes", indexes(bytes.ToUpper(ascii), []byte(q.text)))
+		}
+	}
+	return hits, err
+}
+
+// indexes returns where sep is in s, up to maxHitsPerFile times.
+func indexes(s, sep []byte) []int {
+	var found []int
+	for offset := 0; len(found) < maxHitsPerFile; {
+		i := bytes.Index(s[offset:], sep)
+		if i < 0 {
+			break
+		}
+		found = append(found, offset+i)
+		offset += i + 1
+	}
+	return found
+}
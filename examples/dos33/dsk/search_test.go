@@ -0,0 +1,61 @@
+package dsk
+
+import (
+	"slices"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestParseQuery(t *testing.T) {
+	if q, err := ParseQuery("$A9 c1"); err != nil || !slices.Equal(q.bytes, []byte{0xA9, 0xC1}) {
+		t.Errorf("Expected the bytes A9 C1, got %v, %v", q, err)
+	}
+	if q, err := ParseQuery("hello"); err != nil || q.text != "HELLO" {
+		t.Errorf("Expected the text HELLO, got %v, %v", q, err)
+	}
+	for _, bad := range []string{"", " ", "$", "$A9C", "$XY"} {
+		if _, err := ParseQuery(bad); err == nil {
+			t.Errorf("Expected %q to be refused", bad)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	dsk := loadImage(t, "DISK.dsk", dsktest.Image(dsktest.Standard, 254,
+		dsktest.File{Name: "HELLO", Type: dsktest.Text, Data: hiText("SAY HELLO")},
+		dsktest.File{Name: "PROG", Type: dsktest.Binary, Data: dsktest.BinaryData(0x0300, []byte{0xA9, 0xC1, 0x60, 0xC8, 0xC5, 0xCC, 0xCC, 0xCF})},
+	))
+
+	type hit struct {
+		name, where string
+		offset      int
+	}
+	search := func(s string) []hit {
+		q, err := ParseQuery(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found, err := Search(dsk, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hits []hit
+		for _, h := range found {
+			hits = append(hits, hit{h.Name, h.Where, h.Offset})
+		}
+		return hits
+	}
+
+	want := []hit{{"HELLO", "name", 0}, {"HELLO", "listing", 4}, {"PROG", "bytes", 7}}
+	if got := search("Hello"); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	want = []hit{{"PROG", "bytes", 4}}
+	if got := search("$A9C1"); !slices.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if got := search("GOODBYE"); len(got) != 0 {
+		t.Errorf("Expected no hits, got %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package dsk
+
+import (
+	"errors"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/nufx"
+)
+
+// shrinkItImage decodes the disk image in a ShrinkIt disk archive (.sdk). It
+// cannot encode one, so such disks are read-only.
+//
+// ShrinkIt stores disks as ProDOS blocks, so the image is in ProDOS order.
+type shrinkItImage struct {
+	comment string
+}
+
+func (img *shrinkItImage) decode(file []byte) ([]byte, error) {
+	archive, err := nufx.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range archive.Records {
+		if record.Has(nufx.DiskImage) {
+			img.comment = record.Comment()
+			return record.Read(nufx.DiskImage)
+		}
+	}
+	return nil, errors.New("sdk: archive does not hold a disk image")
+}
+
+func (img *shrinkItImage) info() imageInfo {
+	return imageInfo{order: ProDOSOrder, comment: img.comment}
+}
@@ -0,0 +1,153 @@
+package dsk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+/// 2IMG Disk Images
+/*
+http://fileformats.archiveteam.org/wiki/2IMG
+
+A .2mg file wraps a sector or nibble image in a 64-byte header.
+
+offset
+----
+$00-03 "2IMG"
+$04-07 creator ID, such as "CTKG" or "WOOF"
+$08-09 header length (LO/HI format), normally 64
+$0A-0B version, normally 1
+$0C-0F image format: 0 = DOS order, 1 = ProDOS order, 2 = nibbles
+$10-13 flags:
+       bit 31: write protected (locked)
+       bit 8: the volume number in bits 0-7 is valid
+$14-17 number of 512-byte ProDOS blocks, for ProDOS order
+$18-1B offset to the image data
+$1C-1F length of the image data
+$20-23 offset to the comment, or zero
+$24-27 length of the comment
+$28-2B offset to creator-specific data, or zero
+$2C-2F length of creator-specific data
+$30-3F reserved, zero
+
+All numbers are LO/HI format.
+*/
+
+const (
+	twoIMGHeaderSize = 64
+
+	twoIMGDOSOrder    = 0
+	twoIMGProDOSOrder = 1
+	twoIMGNibbles     = 2
+
+	twoIMGLocked      = 1 << 31
+	twoIMGVolumeValid = 1 << 8
+
+	// twoIMGCreator identifies the images made by this package.
+	twoIMGCreator = "WDFS"
+)
+
+// twoIMGImage reads and writes .2mg files. Saving rewrites only the image
+// data, so the header, comment and creator data are preserved.
+type twoIMGImage struct {
+	file    []byte    // the .2mg file as last read or written
+	data    []byte    // the image data within file
+	nibbles *nibImage // the decoder for image data holding nibbles, if any
+	header  imageInfo
+}
+
+func (img *twoIMGImage) decode(file []byte) ([]byte, error) {
+	if len(file) < twoIMGHeaderSize || string(file[:4]) != "2IMG" {
+		return nil, errors.New("2mg: not a 2IMG file")
+	}
+	u32 := func(offset int) int { return int(binary.LittleEndian.Uint32(file[offset:])) }
+	section := func(what string, offsetAt, lengthAt int) ([]byte, error) {
+		offset, length := u32(offsetAt), u32(lengthAt)
+		if offset == 0 || length == 0 {
+			return nil, nil
+		}
+		if offset+length > len(file) {
+			return nil, fmt.Errorf("2mg: %s is truncated", what)
+		}
+		return file[offset:][:length], nil
+	}
+
+	data, err := section("image data", 0x18, 0x1C)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := section("comment", 0x20, 0x24)
+	if err != nil {
+		return nil, err
+	}
+
+	img.file, img.data = file, data
+	img.header = imageInfo{
+		locked:  u32(0x10)&twoIMGLocked != 0,
+		comment: string(comment),
+	}
+
+	switch format := u32(0x0C); format {
+	case twoIMGDOSOrder:
+		img.header.order = DOSOrder
+	case twoIMGProDOSOrder:
+		img.header.order = ProDOSOrder
+	case twoIMGNibbles:
+		img.nibbles = &nibImage{}
+		return img.nibbles.decode(data)
+	default:
+		return nil, fmt.Errorf("2mg: unknown image format %d", format)
+	}
+	return slices.Clone(data), nil
+}
+
+func (img *twoIMGImage) encode(sectors []byte) ([]byte, error) {
+	data := sectors
+	if img.nibbles != nil {
+		var err error
+		if data, err = img.nibbles.encode(sectors); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) != len(img.data) {
+		return nil, fmt.Errorf("2mg: image data changed size from %d to %d bytes", len(img.data), len(data))
+	}
+
+	file := slices.Clone(img.file)
+	offset := int(binary.LittleEndian.Uint32(file[0x18:]))
+	copy(file[offset:], data)
+	img.file, img.data = file, file[offset:][:len(data)]
+	return file, nil
+}
+
+func (img *twoIMGImage) info() imageInfo { return img.header }
+
+// setVolume changes the volume number in the header, if it has one, so that
+// it agrees with the VTOC.
+func (img *twoIMGImage) setVolume(volume byte) {
+	flags := binary.LittleEndian.Uint32(img.file[0x10:])
+	if flags&twoIMGVolumeValid != 0 {
+		binary.LittleEndian.PutUint32(img.file[0x10:], flags&^0xFF|uint32(volume))
+	}
+}
+
+// newTwoIMG returns a .2mg file holding the DOS-ordered sectors of a disk with
+// the given volume number and comment.
+func newTwoIMG(sectors []byte, volume uint, comment string) []byte {
+	header := make([]byte, twoIMGHeaderSize)
+	copy(header, "2IMG"+twoIMGCreator)
+	binary.LittleEndian.PutUint16(header[0x08:], twoIMGHeaderSize)
+	binary.LittleEndian.PutUint16(header[0x0A:], 1)
+	binary.LittleEndian.PutUint32(header[0x0C:], twoIMGDOSOrder)
+	binary.LittleEndian.PutUint32(header[0x10:], twoIMGVolumeValid|uint32(volume&0xFF))
+	binary.LittleEndian.PutUint32(header[0x18:], twoIMGHeaderSize)
+	binary.LittleEndian.PutUint32(header[0x1C:], uint32(len(sectors)))
+	if comment != "" {
+		binary.LittleEndian.PutUint32(header[0x20:], uint32(twoIMGHeaderSize+len(sectors)))
+		binary.LittleEndian.PutUint32(header[0x24:], uint32(len(comment)))
+	}
+	file := append(header, sectors...)
+	return append(file, comment...)
+}
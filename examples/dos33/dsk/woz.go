@@ -0,0 +1,152 @@
+package dsk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+/// WOZ Disk Images
+/*
+https://applesaucefdc.com/woz/reference2/
+
+A WOZ file holds the bitstream of each track as it was read from the floppy.
+
+offset
+----
+$00-03 "WOZ1" or "WOZ2"
+$04-07 FF 0A 0D 0A
+$08-0B CRC32 of the rest of the file, or zero if not computed
+$0C-   chunks: a 4-byte ID, a 4-byte size (LO/HI format), then the data
+
+INFO chunk
+----
+$00    INFO version
+$01    disk type (1 = 5.25", 2 = 3.5")
+$02    write protected (1 = yes)
+...
+
+TMAP chunk
+----
+$00-9F index into TRKS for each quarter track, or FF if blank. Track t is at
+       quarter track 4*t.
+
+TRKS chunk (WOZ1)
+----
+160 tracks of 6656 bytes each:
+$0000-19F5 bitstream
+$19F6-19F7 bytes used
+$19F8-19F9 bit count
+
+TRKS chunk (WOZ2)
+----
+160 entries of 8 bytes each:
+$00-01 starting block (512-byte blocks from the start of the file)
+$02-03 block count
+$04-07 bit count
+*/
+
+// wozImage decodes a WOZ version 1 or 2 file. It cannot encode one.
+type wozImage struct{}
+
+func (wozImage) decode(file []byte) ([]byte, error) {
+	const headerSize = 12
+	if len(file) < headerSize || (string(file[:4]) != "WOZ1" && string(file[:4]) != "WOZ2") ||
+		string(file[4:8]) != "\xFF\x0A\x0D\x0A" {
+		return nil, errors.New("woz: not a WOZ file")
+	}
+	version := file[3] - '0'
+	if crc := binary.LittleEndian.Uint32(file[8:]); crc != 0 && crc != crc32.ChecksumIEEE(file[headerSize:]) {
+		return nil, errors.New("woz: CRC mismatch")
+	}
+
+	chunks := make(map[string][]byte)
+	for rest := file[headerSize:]; len(rest) >= 8; {
+		id := string(rest[:4])
+		size := binary.LittleEndian.Uint32(rest[4:])
+		if uint64(size) > uint64(len(rest)-8) {
+			return nil, fmt.Errorf("woz: %s chunk is truncated", id)
+		}
+		chunks[id] = rest[8:][:size]
+		rest = rest[8+size:]
+	}
+
+	info, tmap, trks := chunks["INFO"], chunks["TMAP"], chunks["TRKS"]
+	if len(info) < 3 || len(tmap) < 160 || trks == nil {
+		return nil, errors.New("woz: missing INFO, TMAP or TRKS chunk")
+	}
+	if info[1] != 1 {
+		return nil, errors.New("woz: only 5.25\" disks are supported")
+	}
+
+	bitstream := func(index int) ([]byte, int, error) {
+		if version == 1 {
+			const trackSize = 6656
+			if (index+1)*trackSize > len(trks) {
+				return nil, 0, fmt.Errorf("woz: track %d is truncated", index)
+			}
+			track := trks[index*trackSize:]
+			return track[:6646], int(binary.LittleEndian.Uint16(track[6648:])), nil
+		}
+		if (index+1)*8 > len(trks) {
+			return nil, 0, fmt.Errorf("woz: track %d is missing", index)
+		}
+		entry := trks[index*8:]
+		start := int(binary.LittleEndian.Uint16(entry[0:])) * 512
+		blocks := int(binary.LittleEndian.Uint16(entry[2:]))
+		bits := int(binary.LittleEndian.Uint32(entry[4:]))
+		if start+blocks*512 > len(file) {
+			return nil, 0, fmt.Errorf("woz: track %d is truncated", index)
+		}
+		return file[start:][:blocks*512], bits, nil
+	}
+
+	// DOS 3.3 disks have 35 tracks, but some have up to 40.
+	numTracks := 35
+	for t := 39; t >= numTracks; t-- {
+		if tmap[4*t] != 0xFF {
+			numTracks = t + 1
+			break
+		}
+	}
+
+	tracks := make([][]byte, numTracks)
+	for t := range tracks {
+		index := tmap[4*t]
+		if index == 0xFF {
+			continue
+		}
+		bits, count, err := bitstream(int(index))
+		if err != nil {
+			return nil, err
+		}
+		if count > len(bits)*8 {
+			return nil, fmt.Errorf("woz: track %d has more bits than bytes", t)
+		}
+		tracks[t] = readNibbles(bits, count)
+	}
+
+	image, _ := decodeNibbleTracks(tracks, sectorsPerNibbleTrack(tracks))
+	return image, nil
+}
+
+// readNibbles returns the disk bytes in a circular bitstream as the Disk II
+// controller would read them, going around twice so no sector is cut in half.
+//
+// Bits are shifted in until the high bit of the byte is set, so zero bits
+// between disk bytes (as in self-sync bytes) are skipped.
+func readNibbles(bits []byte, count int) []byte {
+	nibbles := make([]byte, 0, 2*count/8)
+	latch := byte(0)
+	for i := 0; i < 2*count; i++ {
+		pos := i % count
+		bit := bits[pos/8] >> (7 - pos%8) & 1
+		latch = latch<<1 | bit
+		if latch&0x80 != 0 {
+			nibbles = append(nibbles, latch)
+			latch = 0
+		}
+	}
+	return nibbles
+}
@@ -0,0 +1,87 @@
+package dsk
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// LoadZip reads every disk image in the zip archive at path, recognized by
+// its extension, without extracting them. Members that cannot be read as DOS
+// disks, such as ProDOS volumes, are left out, and the reasons returned as
+// skipped.
+//
+// Each Diskette is named after its member, and after the member's folders
+// too if another member has the same name, so the names are unique.
+//
+// The archive is never written, so the Diskettes are read-only; see
+// [LoadZipOverlay] to keep changes.
+func LoadZip(path string) (disks []*Diskette, skipped []error, err error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zr.Close()
+
+	for _, member := range zr.File {
+		if member.FileInfo().IsDir() || !isDiskImage(member.Name) {
+			continue
+		}
+		buf, err := readZipMember(member)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %s: %w", path, member.Name, err))
+			continue
+		}
+		dsk, err := decodeDiskette(member.Name, buf, true)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		dsk.path = path + "/" + member.Name
+		dsk.archive = path
+		dsk.member = buf
+		dsk.modTime = member.Modified
+		disks = append(disks, dsk)
+	}
+
+	count := make(map[string]int)
+	for _, dsk := range disks {
+		count[dsk.name]++
+	}
+	for _, dsk := range disks {
+		if count[dsk.name] > 1 {
+			member := strings.TrimPrefix(dsk.path, path+"/")
+			dsk.name = strings.ReplaceAll(strings.TrimSuffix(member, filepath.Ext(member)), "/", "!")
+		}
+	}
+	return disks, skipped, nil
+}
+
+// LoadZipOverlay reads the disk images in the zip archive at path like
+// [LoadZip], but changes are stored in sidecar overlay files in dir, one per
+// image.
+func LoadZipOverlay(path, dir string) (disks []*Diskette, skipped []error, err error) {
+	disks, skipped, err = LoadZip(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dsk := range disks {
+		member := strings.TrimPrefix(dsk.path, path+"/")
+		sidecar := filepath.Base(path) + "!" + strings.ReplaceAll(member, "/", "!") + ".overlay"
+		if err := dsk.useOverlay(filepath.Join(dir, sidecar)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return disks, skipped, nil
+}
+
+func readZipMember(member *zip.File) ([]byte, error) {
+	r, err := member.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,129 @@
+package dos33
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+/// ETags and Content Types
+/*
+Every file on a disk has the same ModTime, the disk's, so the ETag that
+webdav.Handler would make from the ModTime and size changes for every file
+whenever any of them does, and not at all when a file is rewritten in place.
+Instead, a file's ETag is a hash of its contents, which for a DOS file are its
+sectors, so caching clients see exactly the files that changed.
+
+Files also say what they hold, rather than leaving it to the handler to sniff:
+the text views are text/plain (or JSON or CSV), the zip export is
+application/zip and DOS files and disk images are application/octet-stream.
+There are no graphics views yet; image/png is meant for those.
+*/
+
+const (
+	typeText   = "text/plain; charset=utf-8"
+	typeBinary = "application/octet-stream"
+)
+
+// textTypes are the content types of the text views, by extension.
+var textTypes = map[string]string{
+	".json": "application/json",
+	".csv":  "text/csv; charset=utf-8",
+}
+
+// textType returns the content type of the text view called name.
+func textType(name string) string {
+	if t, ok := textTypes[path.Ext(name)]; ok {
+		return t
+	}
+	return typeText
+}
+
+// imageType returns the content type of the disk download called name.
+func imageType(name string) string {
+	if path.Ext(name) == ".zip" {
+		return "application/zip"
+	}
+	return typeBinary
+}
+
+// hashETag returns an ETag for data.
+func hashETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%x"`, sum[:16])
+}
+
+// ETag implements [webdav.ETager]. Without an etag func, webdav.Handler makes
+// its own from the ModTime and size.
+func (f *fileInfo) ETag(context.Context) (string, error) {
+	if f.etag == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return f.etag()
+}
+
+// ContentType implements [webdav.ContentTyper].
+func (f *fileInfo) ContentType(context.Context) (string, error) {
+	if f.contentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return f.contentType, nil
+}
+
+// etag returns a hash of the file's sectors. A file whose sectors cannot be
+// read gets the handler's ETag instead, so that it is still listed.
+func (f *dskFile) etag() (string, error) {
+	r, err := f.dsk.Open(f.file)
+	if err != nil {
+		return "", webdav.ErrNotImplemented
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, 0, r.Size())); err != nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)[:16]), nil
+}
+
+// withContentTypes wraps h so that a GET or HEAD of a file is answered with the
+// file's own content type. webdav.Handler only uses it for the getcontenttype
+// property, and leaves GET to http.ServeContent, which sniffs.
+func withContentTypes(h *webdav.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			if t, ok := contentTypeOf(r.Context(), h, r.URL.Path); ok {
+				w.Header().Set("Content-Type", t)
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// contentTypeOf returns the content type of the file at urlPath, if it has one.
+func contentTypeOf(ctx context.Context, h *webdav.Handler, urlPath string) (string, bool) {
+	name, ok := strings.CutPrefix(urlPath, h.Prefix)
+	if !ok {
+		return "", false
+	}
+	f, err := h.FileSystem.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return "", false
+	}
+	typer, ok := info.(webdav.ContentTyper)
+	if !ok {
+		return "", false
+	}
+	t, err := typer.ContentType(ctx)
+	return t, err == nil
+}
@@ -0,0 +1,160 @@
+package dos33
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+// imageFile is a download of the whole disk, encoded as an image file in the
+// format implied by its name's extension, or as a zip of its files.
+type imageFile struct {
+	anyFile
+	dsk     *dsk.Diskette
+	name    string
+	data    *lazyBytes // shared by every open of the file
+	content *bytes.Reader
+}
+
+func newImageFile(d *dsk.Diskette, name string) *imageFile {
+	return &imageFile{dsk: d, name: name, data: &lazyBytes{}}
+}
+
+func (f *imageFile) Open() (webdav.File, error) {
+	return &imageFile{dsk: f.dsk, name: f.name, data: f.data}, nil
+}
+func (f *imageFile) Read(p []byte) (int, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Read(p)
+}
+func (f *imageFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.load(); err != nil {
+		return 0, err
+	}
+	return f.content.Seek(offset, whence)
+}
+func (*imageFile) Write([]byte) (int, error) { return 0, errors.ErrUnsupported }
+
+// Stat encodes nothing: the file is encoded only once its size, ETag or
+// contents are asked for. Its size is zero if it cannot be encoded.
+func (f *imageFile) Stat() (fs.FileInfo, error) {
+	return &fileInfo{
+		name: f.name,
+		sizeOf: func() int64 {
+			buf, _ := f.data.get(f.encode) // Read reports the error
+			return int64(len(buf))
+		},
+		modTime: f.dsk.ModTime(),
+
+		etag: func() (string, error) {
+			buf, err := f.data.get(f.encode)
+			if err != nil {
+				return "", err
+			}
+			return hashETag(buf), nil
+		},
+		contentType: imageType(f.name),
+	}, nil
+}
+func (*imageFile) Delete() error { return errors.ErrUnsupported }
+
+func (f *imageFile) load() error {
+	if f.content == nil {
+		buf, err := f.data.get(f.encode)
+		if err != nil {
+			return err
+		}
+		f.content = bytes.NewReader(buf)
+	}
+	return nil
+}
+
+// encode returns the disk encoded as the file.
+func (f *imageFile) encode() ([]byte, error) {
+	if path.Ext(f.name) == ".zip" {
+		return exportZip(f.dsk)
+	}
+	return f.dsk.Encode(path.Ext(f.name))
+}
+
+// exportZip returns a zip archive of the files in the catalog of d.
+//
+// Each file is stored raw, as it is on the disk with any BASIC or BINARY
+// header, named with its type byte in hex and, for a BINARY file, its load
+// address, as in "HELLO#02" or "GAME#040800". BASIC and TEXT files also have a
+// readable version, as in "HELLO#02.txt". A file that cannot be read, as on a
+// damaged disk, is left out, and "NAME.error.txt" says why.
+func exportZip(d *dsk.Diskette) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, data []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: d.ModTime()})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	names := d.PathNames()
+	for i, file := range d.Catalog() {
+		if file.IsDeleted() {
+			continue
+		}
+		entries, err := exportFile(d, file, names[i])
+		if err != nil {
+			entries = []zipEntry{{names[i] + ".error.txt", []byte(err.Error() + "\n")}}
+		}
+		for _, entry := range entries {
+			if err := add(entry.name, entry.data); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zipEntry is a file to be added to a zip archive.
+type zipEntry struct {
+	name string
+	data []byte
+}
+
+// exportFile returns the entries of file in the zip of its disk: the raw file
+// and any readable version.
+func exportFile(d *dsk.Diskette, file dsk.FileEntry, pathName string) ([]zipEntry, error) {
+	data, err := d.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s#%02X", pathName, byte(file.Type()))
+	if file.IsBinary() {
+		_, address, err := d.Contents(file)
+		if err != nil {
+			return nil, err
+		}
+		name += fmt.Sprintf("%04X", address)
+	}
+	entries := []zipEntry{{name, data}}
+
+	listing, ok, err := d.Listing(file)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		entries = append(entries, zipEntry{name + ".txt", []byte(listing)})
+	}
+	return entries, nil
+}
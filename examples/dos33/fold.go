@@ -0,0 +1,76 @@
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+/// Folded Names
+/*
+DOS names are uppercase, but clients do not always ask for them that way:
+Windows and the Finder may change the case, and macOS sends names in Unicode
+Normalization Form D (NFD), with accents as separate combining marks, where
+the host's file names are usually precomposed.
+
+With Options.FoldNames, a name that does not match exactly is looked up by its
+folded key instead: decomposed to NFD and then uppercased, so that a name in
+NFC or NFD finds the same file. The names of DOS files are unchanged by NFD
+(see [dsk.Filename.PathName]), but those of disk images and archives may not
+be.
+
+Listings always show the canonical names.
+*/
+
+// errAmbiguousName is returned when a folded name matches more than one file.
+var errAmbiguousName = errors.New("ambiguous name")
+
+// lookup returns the name and file in children that name refers to: the one
+// with that exact name or, if fold is set, the only one with the same folded
+// key.
+func lookup(children *dirEntries, name string, fold bool) (string, fileWrapper, error) {
+	if child, found := children.get(name); found {
+		return name, child, nil
+	} else if !fold {
+		return "", nil, os.ErrNotExist
+	}
+
+	key := foldKey(name)
+	var matches []string
+	for _, childName := range children.names() {
+		if foldKey(childName) == key {
+			matches = append(matches, childName)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil, os.ErrNotExist
+	case 1:
+		child, _ := children.get(matches[0])
+		return matches[0], child, nil
+	default:
+		return "", nil, fmt.Errorf("%q could be any of %q: %w", name, matches, errAmbiguousName)
+	}
+}
+
+// createName returns the name to create in dir for the name a client asked
+// for, which when folding names may be a lock on a file named in another case
+// or form.
+func createName(dir fileWrapper, name string, fold bool) string {
+	suffix := snLock("")
+	if !fold || len(name) <= len(suffix) || !strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		return name
+	}
+	if filename, _, err := lookup(dir.Children(), name[:len(name)-len(suffix)], true); err == nil {
+		return snLock(filename)
+	}
+	return name
+}
+
+// foldKey returns the key that name is looked up by when folding names.
+func foldKey(name string) string {
+	return strings.ToUpper(norm.NFD.String(name))
+}
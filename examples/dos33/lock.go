@@ -0,0 +1,226 @@
+package dos33
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+/// WebDAV Locks
+/*
+A WebDAV lock on a DOS file locks the file on the disk, as LOCK does in DOS,
+and unlocking it unlocks the file. Every lock is also kept in a
+[webdav.NewMemLS], which settles conflicts between clients as usual.
+
+The webdav.Handler also takes a short lock of its own around every request
+that changes something, through the same LockSystem. Only a lock taken by a
+LOCK request is a client's, so withLocks serves LOCK with [clientLocks] in its
+place; every other lock leaves the disk alone, whatever its details. A disk
+that cannot be written, such as one in a zip, still takes a client's lock, but
+only in memory.
+
+A locked DOS file shows its lock in the lockdiscovery property, whether it was
+locked through WebDAV, with a ",locked" file or on the disk itself.
+*/
+
+var propLockDiscovery = xml.Name{Space: "DAV:", Local: "lockdiscovery"}
+
+// dosLockSystem is the [webdav.LockSystem] of a dos33FS.
+type dosLockSystem struct {
+	dfs *dos33FS
+	mem webdav.LockSystem
+
+	mu    sync.Mutex
+	locks map[string]*dosLock // by token
+}
+
+// dosLock is a WebDAV lock on a DOS file, which has its lock bit set.
+type dosLock struct {
+	dsk     *dsk.Diskette
+	file    dsk.FileEntry
+	details webdav.LockDetails
+	expires time.Time // zero if never
+}
+
+func newLockSystem(dfs *dos33FS) *dosLockSystem {
+	return &dosLockSystem{dfs: dfs, mem: webdav.NewMemLS(), locks: make(map[string]*dosLock)}
+}
+
+func (ls *dosLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	ls.collect(now)
+	return ls.mem.Confirm(now, name0, name1, conditions...)
+}
+
+// Create takes the lock webdav.Handler holds for the length of a request.
+func (ls *dosLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	ls.collect(now)
+	return ls.mem.Create(now, details)
+}
+
+// clientLocks is the LockSystem of a LOCK request, whose lock is a client's
+// and so locks the DOS file, if it is one.
+type clientLocks struct {
+	*dosLockSystem
+}
+
+func (ls clientLocks) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	ls.collect(now)
+	file, _ := ls.dfs.lookupFile(details.Root)
+	if file != nil && file.file.IsLocked() {
+		return "", webdav.ErrLocked
+	}
+	token, err := ls.mem.Create(now, details)
+	if err != nil || file == nil {
+		return token, err
+	}
+
+	if err := file.dsk.Lock(file.file); errors.Is(err, os.ErrPermission) {
+		return token, nil // the disk cannot be written, so the lock is only in memory
+	} else if err != nil {
+		ls.mem.Unlock(now, token)
+		return "", err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.locks[token] = &dosLock{dsk: file.dsk, file: file.file, details: details, expires: expiry(now, details.Duration)}
+	return token, nil
+}
+
+// withLocks wraps next, which serves h, so that a LOCK request is served by h
+// with [clientLocks] as its LockSystem.
+func (dfs *dos33FS) withLocks(h *webdav.Handler, next http.Handler) http.Handler {
+	lockHandler := *h
+	lockHandler.LockSystem = clientLocks{dfs.locks}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "LOCK" {
+			lockHandler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (ls *dosLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	ls.collect(now)
+	details, err := ls.mem.Refresh(now, token, duration)
+	if err != nil {
+		return details, err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if lock, ok := ls.locks[token]; ok {
+		lock.details, lock.expires = details, expiry(now, duration)
+	}
+	return details, nil
+}
+
+func (ls *dosLockSystem) Unlock(now time.Time, token string) error {
+	ls.collect(now)
+	if err := ls.mem.Unlock(now, token); err != nil {
+		return err
+	}
+	ls.mu.Lock()
+	lock, ok := ls.locks[token]
+	delete(ls.locks, token)
+	ls.mu.Unlock()
+	if ok {
+		return lock.dsk.Unlock(lock.file)
+	}
+	return nil
+}
+
+// collect forgets the locks that have expired, unlocking their files, and
+// those whose files were unlocked some other way.
+func (ls *dosLockSystem) collect(now time.Time) {
+	ls.mu.Lock()
+	var expired []*dosLock
+	for token, lock := range ls.locks {
+		if lock.file.IsLocked() && (lock.expires.IsZero() || now.Before(lock.expires)) {
+			continue
+		}
+		delete(ls.locks, token)
+		ls.mem.Unlock(now, token)
+		if lock.file.IsLocked() {
+			expired = append(expired, lock)
+		}
+	}
+	ls.mu.Unlock()
+
+	for _, lock := range expired {
+		lock.dsk.Unlock(lock.file)
+	}
+}
+
+// lockDiscovery returns the lockdiscovery property of a DOS file, describing
+// its lock, if it is locked.
+func (ls *dosLockSystem) lockDiscovery(f *dskFile) (webdav.Property, bool) {
+	if !f.file.IsLocked() {
+		return webdav.Property{}, false
+	}
+	token, details := "", webdav.LockDetails{Duration: -1, ZeroDepth: true}
+	if ls != nil {
+		ls.collect(time.Now())
+		ls.mu.Lock()
+		for t, lock := range ls.locks {
+			if &lock.file[0] == &f.file[0] {
+				token, details = t, lock.details
+			}
+		}
+		ls.mu.Unlock()
+	}
+
+	timeout := "Infinite"
+	if details.Duration >= 0 {
+		timeout = fmt.Sprintf("Second-%d", details.Duration/time.Second)
+	}
+	var sb strings.Builder
+	sb.WriteString(`<activelock xmlns="DAV:">`)
+	sb.WriteString(`<locktype><write/></locktype><lockscope><exclusive/></lockscope><depth>0</depth>`)
+	if details.OwnerXML != "" {
+		sb.WriteString("<owner>" + details.OwnerXML + "</owner>")
+	}
+	sb.WriteString("<timeout>" + timeout + "</timeout>")
+	if token != "" {
+		sb.WriteString("<locktoken><href>" + escapeXML(token) + "</href></locktoken>")
+	}
+	if details.Root != "" {
+		sb.WriteString("<lockroot><href>" + escapeXML(details.Root) + "</href></lockroot>")
+	}
+	sb.WriteString(`</activelock>`)
+	return webdav.Property{XMLName: propLockDiscovery, InnerXML: []byte(sb.String())}, true
+}
+
+// expiry returns when a lock of duration taken at now expires, or zero if it
+// never does.
+func expiry(now time.Time, duration time.Duration) time.Time {
+	if duration < 0 {
+		return time.Time{}
+	}
+	return now.Add(duration)
+}
+
+func escapeXML(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// lookupFile returns the DOS file at name, if there is one.
+func (dfs *dos33FS) lookupFile(name string) (*dskFile, error) {
+	file, _, err := walk(&rootDir{dfs: dfs}, strings.TrimLeft(name, "/"), dfs.fold)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := file.(*dskFile); ok && !f.file.IsDeleted() {
+		return f, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,269 @@
+package nufx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+/// LZW/1 and LZW/2
+/*
+Data is compressed in chunks of 4096 bytes, the last one padded with zeros.
+Each chunk is run-length encoded and then, if that made it smaller, LZW
+compressed.
+
+LZW/1 thread data
+----
+$00-01 CRC-16 of the uncompressed chunks, padding included
+$02    disk volume number
+$03    RLE delimiter
+Each chunk:
+$00-01 length after RLE; 4096 means RLE was not applied
+$02    1 if the chunk is LZW compressed
+$03-   the chunk
+
+LZW/2 thread data
+----
+$00    disk volume number
+$01    RLE delimiter
+Each chunk:
+$00-01 length after RLE in bits 0-12; bit 15 is set if it is LZW compressed
+$02-03 if LZW compressed, the length of the chunk including this header
+$xx-   the chunk
+
+Run-length encoding
+----
+A run of the same byte is stored as the delimiter, the byte, and the length
+of the run minus one.
+
+LZW
+----
+Codes are stored least significant bit first, starting at 9 bits wide and
+growing to 12 bits as the table fills. Codes below $100 are bytes; $100 clears
+the table; new entries start at $101.
+
+LZW/1 starts every chunk with an empty table. LZW/2 carries the table over
+from one chunk to the next, except after a chunk that is not LZW compressed.
+*/
+
+const (
+	chunkSize = 4096
+
+	clearCode = 0x100
+	firstCode = 0x101
+	maxCodes  = 0x1000
+)
+
+// expandLZW returns the first size bytes of the LZW/1 or LZW/2 compressed data.
+func expandLZW(data []byte, size int, lzw2 bool) ([]byte, error) {
+	var crc uint16
+	if !lzw2 {
+		if len(data) < 2 {
+			return nil, errors.New("lzw: thread is truncated")
+		}
+		crc, data = binary.LittleEndian.Uint16(data), data[2:]
+	}
+	if len(data) < 2 {
+		return nil, errors.New("lzw: thread is truncated")
+	}
+	delimiter, data := data[1], data[2:]
+
+	table := &lzwTable{}
+	table.reset()
+	out := make([]byte, 0, (size+chunkSize-1)/chunkSize*chunkSize)
+	for len(out) < size {
+		var rleLength int
+		var compressed bool
+		var chunk []byte
+		if lzw2 {
+			if len(data) < 2 {
+				return nil, errors.New("lzw: chunk header is truncated")
+			}
+			word := binary.LittleEndian.Uint16(data)
+			rleLength, compressed = int(word&0x1FFF), word&0x8000 != 0
+			if compressed {
+				if len(data) < 4 {
+					return nil, errors.New("lzw: chunk header is truncated")
+				}
+				length := int(binary.LittleEndian.Uint16(data[2:]))
+				if length < 4 || length > len(data) {
+					return nil, errors.New("lzw: chunk is truncated")
+				}
+				chunk, data = data[4:length], data[length:]
+			} else {
+				data = data[2:]
+			}
+		} else {
+			if len(data) < 3 {
+				return nil, errors.New("lzw: chunk header is truncated")
+			}
+			rleLength, compressed = int(binary.LittleEndian.Uint16(data)), data[2] == 1
+			data = data[3:]
+			chunk = data
+		}
+		if rleLength > chunkSize {
+			return nil, fmt.Errorf("lzw: chunk of %d bytes is too long", rleLength)
+		}
+
+		if compressed {
+			if !lzw2 {
+				table.reset()
+			}
+			expanded, used, err := table.expand(chunk, rleLength)
+			if err != nil {
+				return nil, err
+			}
+			if !lzw2 {
+				data = data[used:]
+			}
+			chunk = expanded
+		} else {
+			if rleLength > len(data) {
+				return nil, errors.New("lzw: chunk is truncated")
+			}
+			chunk, data = data[:rleLength], data[rleLength:]
+			table.reset()
+		}
+
+		if rleLength < chunkSize {
+			var err error
+			if chunk, err = unRLE(chunk, delimiter); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, chunk...)
+	}
+
+	if !lzw2 && crc != crc16(0, out) {
+		return nil, errors.New("lzw: CRC mismatch")
+	}
+	return out[:size], nil
+}
+
+// unRLE expands a run-length encoded chunk.
+func unRLE(data []byte, delimiter byte) ([]byte, error) {
+	out := make([]byte, 0, chunkSize)
+	for i := 0; i < len(data); i++ {
+		if data[i] != delimiter {
+			out = append(out, data[i])
+			continue
+		}
+		if i+2 >= len(data) {
+			return nil, errors.New("rle: run is truncated")
+		}
+		for n := int(data[i+2]); n >= 0; n-- {
+			out = append(out, data[i+1])
+		}
+		i += 2
+	}
+	if len(out) != chunkSize {
+		return nil, fmt.Errorf("rle: chunk expanded to %d bytes instead of %d", len(out), chunkSize)
+	}
+	return out, nil
+}
+
+// lzwTable is the string table of an LZW decoder. Each entry is a previous
+// entry (its prefix) followed by one more byte.
+type lzwTable struct {
+	prefix [maxCodes]uint16
+	suffix [maxCodes]byte
+	entry  int // the next entry to add
+	prev   int // the previous code read, or -1 at the start
+}
+
+func (t *lzwTable) reset() {
+	t.entry, t.prev = firstCode, -1
+}
+
+// expand decodes codes from data until it has size bytes, and returns them
+// with the number of bytes of data it used.
+func (t *lzwTable) expand(data []byte, size int) ([]byte, int, error) {
+	out := make([]byte, 0, size)
+	bit := 0
+	for len(out) < size {
+		// The width is decided by the entry the next code will add.
+		width := codeWidth(t.entry + 1)
+		if bit+width > 8*len(data) {
+			return nil, 0, errors.New("lzw: chunk is truncated")
+		}
+		code := 0
+		for i := 0; i < width; i++ {
+			code |= int(data[(bit+i)/8]>>((bit+i)%8)&1) << i
+		}
+		bit += width
+
+		if code == clearCode {
+			t.reset()
+			continue
+		}
+
+		start := len(out)
+		switch {
+		case t.prev < 0:
+			if code > 0xFF {
+				return nil, 0, fmt.Errorf("lzw: bad first code $%03X", code)
+			}
+			out = append(out, byte(code))
+		case code < t.entry:
+			out = t.appendString(out, code)
+		case code == t.entry:
+			// The code being defined: the previous string and its first byte.
+			out = t.appendString(out, t.prev)
+			out = append(out, out[start])
+		default:
+			return nil, 0, fmt.Errorf("lzw: bad code $%03X", code)
+		}
+		if len(out) > size {
+			return nil, 0, errors.New("lzw: chunk expanded too far")
+		}
+
+		if t.prev >= 0 && t.entry < maxCodes {
+			t.prefix[t.entry], t.suffix[t.entry] = uint16(t.prev), out[start]
+			t.entry++
+		}
+		t.prev = code
+	}
+	return out, (bit + 7) / 8, nil
+}
+
+// appendString appends the bytes of the string for code to out.
+func (t *lzwTable) appendString(out []byte, code int) []byte {
+	start := len(out)
+	for ; code > 0xFF; code = int(t.prefix[code]) {
+		out = append(out, t.suffix[code])
+	}
+	out = append(out, byte(code))
+	slices.Reverse(out[start:])
+	return out
+}
+
+// codeWidth returns the number of bits in a code read when next is the number
+// of entries the table will have after it.
+func codeWidth(next int) int {
+	switch {
+	case next < 0x200:
+		return 9
+	case next < 0x400:
+		return 10
+	case next < 0x800:
+		return 11
+	default:
+		return 12
+	}
+}
+
+// crc16 updates crc with data using the CCITT polynomial, as in XMODEM.
+func crc16(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,331 @@
+// Package nufx reads NuFX archives, as created by ShrinkIt on the Apple II.
+//
+// A file archive (.shk) holds files and their ProDOS attributes, while a disk
+// archive (.sdk) holds a single disk image.
+package nufx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/// NuFX Archives
+/*
+https://nulib.com/library/FTN.e08002.htm
+
+All numbers are LO/HI format.
+
+Master Header Block
+----
+$00-05 "NuFile" with alternating high bits: 4E F5 46 E9 6C E5
+$06-07 CRC-16 of $08-2F
+$08-0B number of records
+$0C-13 date the archive was created
+$14-1B date the archive was last modified
+$1C-1D master version
+$1E-25 reserved
+$26-29 length of the archive (master version 1 and up)
+$2A-2F reserved
+
+Each record follows, starting with its header.
+
+Record Header
+----
+$00-03 "NuFX" with alternating high bits: 4E F5 46 D8
+$04-05 CRC-16 of the header
+$06-07 attrib_count: the length of the header up to the filename length
+$08-09 record version
+$0A-0D number of threads
+$0E-0F file system ID (1 = ProDOS, 2 = DOS 3.3, ...)
+$10-11 file system info: the path separator in the low byte
+$12-15 access
+$16-19 file type
+$1A-1D aux type, or the number of blocks in a disk image
+$1E-1F storage type, or the block size of a disk image
+$20-27 date created
+$28-2F date modified
+$30-37 date archived
+$38-39 length of the option list (record version 1 and up)
+...    option list and any attributes up to attrib_count
++0-1   length of the filename, normally 0 (see the filename thread)
++2-    filename
+
+The thread headers follow, 16 bytes each:
+$00-01 thread class: 0 = message, 1 = control, 2 = data, 3 = filename
+$02-03 thread format: 0 = uncompressed, 2 = LZW/1, 3 = LZW/2, ...
+$04-05 thread kind, which depends on the class:
+       message: 1 = comment
+       data: 0 = data fork, 1 = disk image, 2 = resource fork
+       filename: 0 = filename
+$06-07 thread CRC
+$08-0B length of the thread's data when uncompressed
+$0C-0F length of the thread's data in the archive
+
+Then the data of each thread, in order.
+
+Dates
+----
+$00 second, $01 minute, $02 hour, $03 year - 1900, $04 day - 1, $05 month - 1,
+$06 filler, $07 day of the week
+*/
+
+// ThreadKind is the kind of data held by a record's data thread.
+type ThreadKind uint16
+
+// Kinds of data threads.
+const (
+	DataFork     ThreadKind = 0
+	DiskImage    ThreadKind = 1
+	ResourceFork ThreadKind = 2
+)
+
+// Thread classes.
+const (
+	classMessage  = 0
+	classData     = 2
+	classFilename = 3
+
+	kindComment = 1
+)
+
+// Thread formats.
+const (
+	formatUncompressed = 0
+	formatLZW1         = 2
+	formatLZW2         = 3
+)
+
+const (
+	masterHeaderSize = 48
+	threadHeaderSize = 16
+	minAttribCount   = 58
+)
+
+var (
+	masterID = "\x4E\xF5\x46\xE9\x6C\xE5"
+	recordID = "\x4E\xF5\x46\xD8"
+)
+
+// Archive is a NuFX archive read into memory.
+type Archive struct {
+	Created  time.Time
+	Modified time.Time
+	Records  []*Record
+}
+
+// Record is a file or disk image in an Archive.
+type Record struct {
+	Filename    string // the path within the archive, with Separator between parts
+	Separator   byte
+	FileSystem  uint16
+	Access      uint32
+	FileType    uint32
+	AuxType     uint32
+	StorageType uint16
+	Created     time.Time
+	Modified    time.Time
+	Archived    time.Time
+
+	threads []thread
+}
+
+type thread struct {
+	class, format, kind uint16
+	size                int    // length when uncompressed
+	data                []byte // as stored in the archive
+}
+
+// Read parses the NuFX archive in data.
+func Read(data []byte) (*Archive, error) {
+	if len(data) < masterHeaderSize || string(data[:6]) != masterID {
+		return nil, errors.New("nufx: not a NuFX archive")
+	}
+	if crc := binary.LittleEndian.Uint16(data[6:]); crc != crc16(0, data[8:masterHeaderSize]) {
+		return nil, errors.New("nufx: master header CRC mismatch")
+	}
+
+	archive := &Archive{
+		Created:  when(data[0x0C:]),
+		Modified: when(data[0x14:]),
+	}
+	count := binary.LittleEndian.Uint32(data[0x08:])
+	rest := data[masterHeaderSize:]
+	for i := uint32(0); i < count; i++ {
+		record, n, err := readRecord(rest)
+		if err != nil {
+			return nil, fmt.Errorf("nufx: record %d: %w", i, err)
+		}
+		archive.Records = append(archive.Records, record)
+		rest = rest[n:]
+	}
+	return archive, nil
+}
+
+// readRecord parses the record at the start of data and returns it with its
+// length in bytes.
+func readRecord(data []byte) (*Record, int, error) {
+	if len(data) < minAttribCount+2 || string(data[:4]) != recordID {
+		return nil, 0, errors.New("not a NuFX record")
+	}
+	u16 := func(offset int) uint16 { return binary.LittleEndian.Uint16(data[offset:]) }
+	u32 := func(offset int) uint32 { return binary.LittleEndian.Uint32(data[offset:]) }
+
+	attribCount := int(u16(0x06))
+	if attribCount < minAttribCount || attribCount+2 > len(data) {
+		return nil, 0, errors.New("header is truncated")
+	}
+	nameLength := int(u16(attribCount))
+	threadsAt := attribCount + 2 + nameLength
+	numThreads := int(u32(0x0A))
+	if threadsAt+numThreads*threadHeaderSize > len(data) {
+		return nil, 0, errors.New("thread headers are truncated")
+	}
+
+	record := &Record{
+		Filename:    string(data[attribCount+2 : threadsAt]),
+		Separator:   byte(u16(0x10)),
+		FileSystem:  u16(0x0E),
+		Access:      u32(0x12),
+		FileType:    u32(0x16),
+		AuxType:     u32(0x1A),
+		StorageType: u16(0x1E),
+		Created:     when(data[0x20:]),
+		Modified:    when(data[0x28:]),
+		Archived:    when(data[0x30:]),
+	}
+	if record.Separator == 0 {
+		record.Separator = '/'
+	}
+
+	offset := threadsAt + numThreads*threadHeaderSize
+	for i := 0; i < numThreads; i++ {
+		header := data[threadsAt+i*threadHeaderSize:]
+		stored := int(binary.LittleEndian.Uint32(header[0x0C:]))
+		if stored < 0 || offset+stored > len(data) {
+			return nil, 0, fmt.Errorf("thread %d is truncated", i)
+		}
+		t := thread{
+			class:  binary.LittleEndian.Uint16(header[0x00:]),
+			format: binary.LittleEndian.Uint16(header[0x02:]),
+			kind:   binary.LittleEndian.Uint16(header[0x04:]),
+			size:   int(binary.LittleEndian.Uint32(header[0x08:])),
+			data:   data[offset:][:stored],
+		}
+		offset += stored
+		record.threads = append(record.threads, t)
+
+		if t.class == classFilename && t.kind == 0 {
+			name, err := t.read()
+			if err != nil {
+				return nil, 0, fmt.Errorf("filename: %w", err)
+			}
+			record.Filename = string(name)
+		}
+	}
+
+	// Some file systems store names in Hi-ASCII.
+	name := []byte(record.Filename)
+	for i := range name {
+		name[i] &= 0x7F
+	}
+	record.Filename = string(name)
+
+	return record, offset, nil
+}
+
+// Path returns the parts of the record's filename.
+func (r *Record) Path() []string {
+	return strings.Split(strings.Trim(r.Filename, string(r.Separator)), string(r.Separator))
+}
+
+// Has reports whether the record has a data thread of the given kind.
+func (r *Record) Has(kind ThreadKind) bool { return r.data(kind) != nil }
+
+// Size returns the uncompressed length of the record's data thread of the
+// given kind, or zero if it has none.
+func (r *Record) Size(kind ThreadKind) int {
+	t := r.data(kind)
+	if t == nil {
+		return 0
+	}
+	return r.size(t)
+}
+
+// Read returns the uncompressed data of the record's thread of the given kind.
+func (r *Record) Read(kind ThreadKind) ([]byte, error) {
+	t := r.data(kind)
+	if t == nil {
+		return nil, fmt.Errorf("nufx: %s has no thread of kind %d", r.Filename, kind)
+	}
+	saved := *t
+	saved.size = r.size(t)
+	data, err := saved.read()
+	if err != nil {
+		return nil, fmt.Errorf("nufx: %s: %w", r.Filename, err)
+	}
+	return data, nil
+}
+
+// Comment returns the record's comment, if it has one.
+func (r *Record) Comment() string {
+	for _, t := range r.threads {
+		if t.class == classMessage && t.kind == kindComment {
+			if comment, err := t.read(); err == nil {
+				return strings.TrimRight(strings.ReplaceAll(string(comment), "\r", "\n"), "\x00")
+			}
+		}
+	}
+	return ""
+}
+
+func (r *Record) data(kind ThreadKind) *thread {
+	for i, t := range r.threads {
+		if t.class == classData && t.kind == uint16(kind) {
+			return &r.threads[i]
+		}
+	}
+	return nil
+}
+
+// size returns the uncompressed length of t. The length of a disk image is
+// often missing from its thread header, but the record has it as the block
+// size and count.
+func (r *Record) size(t *thread) int {
+	if t.kind == uint16(DiskImage) && r.StorageType != 0 && r.AuxType != 0 {
+		return int(r.StorageType) * int(r.AuxType)
+	}
+	return t.size
+}
+
+// read returns the uncompressed data of t.
+func (t *thread) read() ([]byte, error) {
+	switch t.format {
+	case formatUncompressed:
+		if t.size > len(t.data) {
+			return nil, errors.New("thread is truncated")
+		}
+		return t.data[:t.size], nil
+	case formatLZW1:
+		return expandLZW(t.data, t.size, false)
+	case formatLZW2:
+		return expandLZW(t.data, t.size, true)
+	default:
+		return nil, fmt.Errorf("thread format %d is not supported", t.format)
+	}
+}
+
+// when converts a NuFX date to a time, or the zero time if it is unset.
+func when(b []byte) time.Time {
+	second, minute, hour, year, day, month := b[0], b[1], b[2], b[3], b[4], b[5]
+	if second|minute|hour|year|day|month == 0 {
+		return time.Time{}
+	}
+	y := 1900 + int(year)
+	if y < 1940 {
+		y += 100 // years before 1940 are taken to be after 2000
+	}
+	return time.Date(y, time.Month(month)+1, int(day)+1, int(hour), int(minute), int(second), 0, time.Local)
+}
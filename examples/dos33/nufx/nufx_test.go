@@ -0,0 +1,140 @@
+package nufx
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk/dsktest"
+)
+
+func TestRead(t *testing.T) {
+	random := make([]byte, 5000)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("THE QUICK BROWN FOX JUMPS OVER THE LAZY DOG\r"), 400)
+	runs := append(bytes.Repeat([]byte{0xDB}, 300), make([]byte, 9000)...)
+
+	records := []dsktest.Record{
+		{Name: "README", FileType: 0x04, Data: text, Comment: "Read me first"},
+		{Name: "GAMES/NOISE", FileType: 0x06, AuxType: 0x2000, Data: random},
+		{Name: "GAMES/RUNS", FileType: 0x06, Data: runs, Resource: text[:100]},
+		{Name: "EMPTY", FileType: 0x04, Data: []byte{}},
+	}
+	for _, format := range []uint16{dsktest.Uncompressed, dsktest.LZW1, dsktest.LZW2} {
+		archive, err := Read(dsktest.ShrinkIt(format, records...))
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if len(archive.Records) != len(records) {
+			t.Fatalf("format %d: expected %d records, got %d", format, len(records), len(archive.Records))
+		}
+		for i, want := range records {
+			got := archive.Records[i]
+			if got.Filename != want.Name || got.FileType != uint32(want.FileType) || got.AuxType != uint32(want.AuxType) {
+				t.Errorf("format %d: expected %s ($%02X/$%04X), got %s ($%02X/$%04X)", format,
+					want.Name, want.FileType, want.AuxType, got.Filename, got.FileType, got.AuxType)
+			}
+			if data, err := got.Read(DataFork); err != nil {
+				t.Errorf("format %d: %s: %v", format, want.Name, err)
+			} else if !bytes.Equal(data, want.Data) {
+				t.Errorf("format %d: %s: data fork differs", format, want.Name)
+			}
+			if got.Size(DataFork) != len(want.Data) {
+				t.Errorf("format %d: %s: expected size %d, got %d", format, want.Name, len(want.Data), got.Size(DataFork))
+			}
+			if got.Has(ResourceFork) != (want.Resource != nil) {
+				t.Errorf("format %d: %s: resource fork is unexpected", format, want.Name)
+			} else if want.Resource != nil {
+				if data, err := got.Read(ResourceFork); err != nil || !bytes.Equal(data, want.Resource) {
+					t.Errorf("format %d: %s: resource fork differs: %v", format, want.Name, err)
+				}
+			}
+			if got.Comment() != want.Comment {
+				t.Errorf("format %d: %s: expected comment %q, got %q", format, want.Name, want.Comment, got.Comment())
+			}
+		}
+	}
+}
+
+func TestReadDiskImage(t *testing.T) {
+	image := dsktest.ProDOSOrder(dsktest.Image(dsktest.Standard, 254, dsktest.File{
+		Name: "HELLO", Type: dsktest.ApplesoftBasic, Data: []byte{0x01, 0x00},
+	}))
+	archive, err := Read(dsktest.ShrinkIt(dsktest.LZW2, dsktest.Record{Name: "DISK", Disk: image}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := archive.Records[0]
+	if !record.Has(DiskImage) || record.Has(DataFork) {
+		t.Fatal("Expected a disk image record")
+	}
+	if data, err := record.Read(DiskImage); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, image) {
+		t.Fatal("Expected the original disk image")
+	}
+}
+
+func TestCorruptArchives(t *testing.T) {
+	archive := dsktest.ShrinkIt(dsktest.LZW1, dsktest.Record{Name: "TEXT", FileType: 0x04, Data: bytes.Repeat([]byte("ABC"), 2000)})
+
+	if _, err := Read(archive[:40]); err == nil {
+		t.Error("Expected an error reading a truncated master header")
+	}
+	if _, err := Read(archive[:len(archive)-10]); err == nil {
+		t.Error("Expected an error reading a truncated record")
+	}
+
+	corrupt := bytes.Clone(archive)
+	corrupt[len(corrupt)-5] ^= 0xFF
+	if ar, err := Read(corrupt); err != nil {
+		t.Fatal(err)
+	} else if _, err := ar.Records[0].Read(DataFork); err == nil {
+		t.Error("Expected an error reading corrupt LZW/1 data")
+	}
+}
+
+// knownArchive does not come from dsktest.ShrinkIt: it was put together by
+// hand from the NuFX and LZW/2 layouts in the comments of nufx.go and lzw.go.
+// It holds HELLO, a TXT file of "HELLO\r" stored uncompressed, and ZEROS, a
+// BIN file at $2000 of 4096 zeros, stored as one LZW/2 chunk: the run-length
+// encoding DB 00 FF repeated 16 times, in 16 nine-bit codes.
+var knownArchive, _ = hex.DecodeString("" +
+	"4EF546E96CE59E07020000000000000000000000000000000000000002000000" +
+	"000000000000260100000000000000004EF546D86F473A000300020000000100" +
+	"2F00E30000000400000000000000010000000000000000000000000000000000" +
+	"0000000000000000000000000300000000000000050000001000000002000000" +
+	"00000000060000000600000048454C4C4F000000000000000000000048454C4C" +
+	"4F0D4EF546D842C83A0003000200000001002F00E30000000600000000200000" +
+	"0100000000000000000000000000000000000000000000000000000000000300" +
+	"0000000000000500000010000000020003000000000000100000180000005A45" +
+	"524F530000000000000000000000FEDB30801600DB00FC0B385020C183061316" +
+	"5C8890A14282")
+
+func TestReadKnownArchive(t *testing.T) {
+	if crc := crc16(0, []byte("123456789")); crc != 0x31C3 {
+		t.Fatalf("Expected the CRC-16/XMODEM check value $31C3, got $%04X", crc)
+	}
+
+	archive, err := Read(knownArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Records) != 2 {
+		t.Fatal("Expected 2 records, got", len(archive.Records))
+	}
+	hello, zeros := archive.Records[0], archive.Records[1]
+	if hello.Filename != "HELLO" || hello.FileType != 0x04 {
+		t.Errorf("Expected HELLO ($04), got %s ($%02X)", hello.Filename, hello.FileType)
+	}
+	if data, err := hello.Read(DataFork); err != nil || string(data) != "HELLO\r" {
+		t.Errorf("Expected HELLO to hold %q, got %q, %v", "HELLO\r", data, err)
+	}
+	if zeros.Filename != "ZEROS" || zeros.FileType != 0x06 || zeros.AuxType != 0x2000 {
+		t.Errorf("Expected ZEROS ($06/$2000), got %s ($%02X/$%04X)", zeros.Filename, zeros.FileType, zeros.AuxType)
+	}
+	if data, err := zeros.Read(DataFork); err != nil || !bytes.Equal(data, make([]byte, 4096)) {
+		t.Errorf("Expected ZEROS to hold 4096 zeros, got %d bytes, %v", len(data), err)
+	}
+}
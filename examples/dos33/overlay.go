@@ -0,0 +1,75 @@
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+// overlayDir is the _dos/overlay/ control area of a disk whose changes are
+// kept in an overlay.
+//
+// Like lock files, its controls are triggered by creating files:
+// creating COMMIT writes the changes into the disk image and creating DISCARD
+// throws them away.
+type overlayDir struct {
+	anyDir
+	dsk *dsk.Diskette
+}
+
+func (dir *overlayDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
+func (dir *overlayDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{
+		name:    snOverlay(),
+		isDir:   true,
+		modTime: dir.dsk.ModTime(),
+	}, nil
+}
+func (dir *overlayDir) Children() *dirEntries {
+	kids := newDirEntries()
+	kids.add(snStatus(), newMemFile(snStatus(), overlayStatus(dir.dsk), dir.dsk.ModTime()))
+	return kids
+}
+func (dir *overlayDir) Create(name string) (webdav.File, error) {
+	var err error
+	switch name {
+	case snCommit():
+		err = dir.dsk.Commit()
+	case snDiscard():
+		err = dir.dsk.Discard()
+	default:
+		err = errors.ErrUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newMemFile(name, "", dir.dsk.ModTime()), nil
+}
+
+// overlayStatus describes the sectors that differ from the disk image.
+func overlayStatus(d *dsk.Diskette) string {
+	sectors := d.OverlaySectors()
+
+	sb := strings.Builder{}
+	sb.WriteString("Overlay Status\n")
+	sb.WriteString("--------------\n")
+	sb.WriteString("\n")
+	if len(sectors) == 0 {
+		sb.WriteString("  No changes; the overlay matches the disk image.\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("  %d sector(s) differ from the disk image.\n", len(sectors)))
+	sb.WriteString("\n")
+	sb.WriteString("  Track  Sector\n")
+	for _, ts := range sectors {
+		sb.WriteString(fmt.Sprintf("  %2d $%.2X  %2d $%.2X\n", ts[0], ts[0], ts[1], ts[1]))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("  Create %s to write the changes into the disk image.\n", snCommit()))
+	sb.WriteString(fmt.Sprintf("  Create %s to throw the changes away.\n", snDiscard()))
+	return sb.String()
+}
@@ -0,0 +1,178 @@
+package dos33
+
+import (
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+/// DOS Properties
+/*
+Each DOS file carries its catalog entry as properties in the dosNamespace:
+
+  type          the type as CATALOG shows it, such as B
+  locked        true or false
+  sectors       the number of sectors it uses, as recorded in the catalog
+  tsListTrack   where its first T/S list is
+  tsListSector
+  address       the load address of a BINARY file, in decimal
+
+PROPPATCH can change type, to a letter or a type byte such as $04, and
+locked. The other properties cannot be changed or removed.
+
+When serving with a hash database, each disk's folder has one more:
+
+  identity      what the disk and its files are known to be, such as
+                "Oregon Trail; HELLO: Oregon Trail loader", or empty
+*/
+
+const dosNamespace = "http://taeber.rapczak.com/webdavfs/dos33/"
+
+var (
+	propType         = xml.Name{Space: dosNamespace, Local: "type"}
+	propLocked       = xml.Name{Space: dosNamespace, Local: "locked"}
+	propSectors      = xml.Name{Space: dosNamespace, Local: "sectors"}
+	propTSListTrack  = xml.Name{Space: dosNamespace, Local: "tsListTrack"}
+	propTSListSector = xml.Name{Space: dosNamespace, Local: "tsListSector"}
+	propAddress      = xml.Name{Space: dosNamespace, Local: "address"}
+	propIdentity     = xml.Name{Space: dosNamespace, Local: "identity"}
+	propCreationDate = xml.Name{Space: "DAV:", Local: "creationdate"}
+)
+
+// DeadProps implements [webdav.DeadPropsHolder] with the file's catalog entry,
+// its creationdate when file times are kept, which webdav.Handler has no way
+// to serve, and, as webdav.Handler leaves it to the LockSystem, its
+// lockdiscovery.
+func (f *dskFile) DeadProps() (map[xml.Name]webdav.Property, error) {
+	props := make(map[xml.Name]webdav.Property)
+	add := func(name xml.Name, value string) {
+		props[name] = webdav.Property{XMLName: name, InnerXML: []byte(escapeXML(value))}
+	}
+	track, sector := f.file.FirstTSList()
+	add(propType, f.file.Type().String())
+	add(propLocked, strconv.FormatBool(f.file.IsLocked()))
+	add(propSectors, strconv.Itoa(int(f.file.SectorsUsed())))
+	add(propTSListTrack, strconv.Itoa(int(track)))
+	add(propTSListSector, strconv.Itoa(int(sector)))
+	if f.file.IsBinary() {
+		if r, err := f.dsk.Open(f.file); err == nil {
+			var header [2]byte
+			if _, err := r.ReadAt(header[:], 0); err == nil {
+				add(propAddress, strconv.Itoa(int(binary.LittleEndian.Uint16(header[:]))))
+			}
+		}
+	}
+	if created, ok := f.dsk.FileCreated(f.file); ok {
+		add(propCreationDate, created.UTC().Format(time.RFC3339))
+	}
+	if prop, ok := f.locks.lockDiscovery(f); ok {
+		props[propLockDiscovery] = prop
+	}
+	return props, nil
+}
+
+// Patch implements [webdav.DeadPropsHolder], changing the type or lock of the
+// file. Either every patch is made or none are.
+func (f *dskFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	var (
+		names  []xml.Name
+		failed = make(map[xml.Name]int) // to the status of each failure
+		ft     *dsk.FileType
+		locked *bool
+	)
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			names = append(names, prop.XMLName)
+			value := strings.TrimSpace(string(prop.InnerXML))
+			switch {
+			case patch.Remove || f.file.IsDeleted():
+				failed[prop.XMLName] = http.StatusForbidden
+			case prop.XMLName == propType:
+				if t, err := dsk.ParseFileType(value); err != nil {
+					failed[prop.XMLName] = http.StatusConflict
+				} else {
+					ft = &t
+				}
+			case prop.XMLName == propLocked:
+				if b, err := strconv.ParseBool(value); err != nil {
+					failed[prop.XMLName] = http.StatusConflict
+				} else {
+					locked = &b
+				}
+			default:
+				failed[prop.XMLName] = http.StatusForbidden
+			}
+		}
+	}
+
+	if len(failed) == 0 {
+		err := f.patch(ft, locked)
+		if errors.Is(err, os.ErrPermission) {
+			for _, name := range names {
+				failed[name] = http.StatusForbidden
+			}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(failed) == 0 {
+		return []webdav.Propstat{propstat(http.StatusOK, names)}, nil
+	}
+
+	byStatus := make(map[int][]xml.Name)
+	for _, name := range names {
+		status, ok := failed[name]
+		if !ok {
+			status = webdav.StatusFailedDependency
+		}
+		byStatus[status] = append(byStatus[status], name)
+	}
+	var stats []webdav.Propstat
+	for _, status := range []int{http.StatusForbidden, http.StatusConflict, webdav.StatusFailedDependency} {
+		if len(byStatus[status]) > 0 {
+			stats = append(stats, propstat(status, byStatus[status]))
+		}
+	}
+	return stats, nil
+}
+
+// patch changes the type and then the lock of the file, where they are given,
+// putting the type back if the lock cannot be changed.
+func (f *dskFile) patch(ft *dsk.FileType, locked *bool) error {
+	prev := f.file.Type()
+	if ft != nil {
+		if err := f.dsk.SetType(f.file, *ft); err != nil {
+			return err
+		}
+	}
+	if locked != nil {
+		change := f.dsk.Unlock
+		if *locked {
+			change = f.dsk.Lock
+		}
+		if err := change(f.file); err != nil {
+			if ft != nil {
+				f.dsk.SetType(f.file, prev)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// propstat returns a Propstat of status for properties without values.
+func propstat(status int, names []xml.Name) webdav.Propstat {
+	stat := webdav.Propstat{Status: status}
+	for _, name := range names {
+		stat.Props = append(stat.Props, webdav.Property{XMLName: name})
+	}
+	return stat
+}
@@ -0,0 +1,128 @@
+package dos33
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/webdav"
+)
+
+/// Quotas
+/*
+Each disk's folder has the RFC 4331 quota properties, counted from the VTOC's
+bit map of free sectors, so that clients like Finder show how much room is
+left on the disk:
+
+  quota-available-bytes  the free sectors, in bytes
+  quota-used-bytes       the sectors in use, in bytes, including DOS itself
+
+As RFC 4331 asks, they are only served to a PROPFIND that names them, and
+not to one for all properties. No PUT can write a DOS file, so none is ever
+refused for want of room.
+*/
+
+var (
+	propQuotaAvailable = xml.Name{Space: "DAV:", Local: "quota-available-bytes"}
+	propQuotaUsed      = xml.Name{Space: "DAV:", Local: "quota-used-bytes"}
+)
+
+// DeadProps implements [webdav.DeadPropsHolder] with the directory's own
+// properties, if it has any, less the quota properties unless they were asked
+// for by name.
+func (d *openDir) DeadProps() (map[xml.Name]webdav.Property, error) {
+	holder, ok := d.dir.(interface {
+		DeadProps() (map[xml.Name]webdav.Property, error)
+	})
+	if !ok {
+		return nil, nil
+	}
+	props, err := holder.DeadProps()
+	if err != nil || d.quotas {
+		return props, err
+	}
+	delete(props, propQuotaAvailable)
+	delete(props, propQuotaUsed)
+	return props, nil
+}
+
+// Patch implements [webdav.DeadPropsHolder]. No property of a directory can be
+// changed.
+func (d *openDir) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	var names []xml.Name
+	for _, patch := range patches {
+		for _, prop := range patch.Props {
+			names = append(names, prop.XMLName)
+		}
+	}
+	return []webdav.Propstat{propstat(http.StatusForbidden, names)}, nil
+}
+
+// DeadProps returns the quota properties of the disk and, when serving with a
+// hash database, its identity.
+func (dir *dskDir) DeadProps() (map[xml.Name]webdav.Property, error) {
+	sectorSize := int64(dir.dsk.SectorSize())
+	total := int64(dir.dsk.NumTracks() * dir.dsk.SectorsPerTrack())
+	free := int64(dir.dsk.FreeSectors())
+	props := map[xml.Name]webdav.Property{
+		propQuotaAvailable: {XMLName: propQuotaAvailable, InnerXML: []byte(strconv.FormatInt(free*sectorSize, 10))},
+		propQuotaUsed:      {XMLName: propQuotaUsed, InnerXML: []byte(strconv.FormatInt((total-free)*sectorSize, 10))},
+	}
+	if dir.hashes == nil {
+		return props, nil
+	}
+	// A disk that cannot be hashed has no identity, and IDENTIFY.txt says why.
+	if id, err := dir.hashes.Identify(dir.dsk); err == nil {
+		known := strings.Join(id.Known(), "; ")
+		props[propIdentity] = webdav.Property{XMLName: propIdentity, InnerXML: []byte(escapeXML(known))}
+	}
+	return props, nil
+}
+
+// quotasKey is the context key of whether a request names a quota property.
+type quotasKey struct{}
+
+// quotasRequested reports whether the request of ctx names a quota property.
+func quotasRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(quotasKey{}).(bool)
+	return requested
+}
+
+// withQuotas wraps h so that a PROPFIND that names a quota property is served
+// with it; see [quotasRequested].
+func withQuotas(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" || r.Body == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if namesQuota(body) {
+			r = r.WithContext(context.WithValue(r.Context(), quotasKey{}, true))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// namesQuota reports whether the propfind body names a quota property.
+func namesQuota(body []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok && (start.Name == propQuotaAvailable || start.Name == propQuotaUsed) {
+			return true
+		}
+	}
+}
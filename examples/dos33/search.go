@@ -0,0 +1,124 @@
+package dos33
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/webdav"
+	"taeber.rapczak.com/webdavfs/examples/dos33/dsk"
+)
+
+/// Search
+/*
+/_search/QUERY/ finds QUERY on every disk, as [dsk.Search] does. It holds a
+folder for each disk with a match, holding the matching files themselves, and
+INDEX.txt, which lists every match: the disk, the file, where in the file it
+was found and at what offset. A disk whose catalog is damaged is searched up
+to the damage, and DISK.error.txt says where it is. /_search/ itself lists
+nothing, as any name in it is a query.
+
+Results are kept for the most recent queries until any disk changes.
+*/
+
+// maxSearches is the number of queries whose results are kept.
+const maxSearches = 32
+
+// searchDir is /_search/.
+type searchDir struct {
+	anyDir
+	dfs *dos33FS
+
+	mu      sync.Mutex
+	results map[string]*searchResults // by query
+	order   []string                  // queries, the oldest first
+}
+
+// searchResults are the results of a query, as of the disks' generations.
+type searchResults struct {
+	generations []uint64
+	dir         *memDir
+}
+
+func (dir *searchDir) Open() (webdav.File, error) { return openDirectory(dir), nil }
+func (dir *searchDir) Stat() (fs.FileInfo, error) {
+	return &fileInfo{name: snSearch(), isDir: true, modTime: dir.dfs.created}, nil
+}
+func (*searchDir) Children() *dirEntries              { return newDirEntries() }
+func (*searchDir) Create(string) (webdav.File, error) { return nil, errors.ErrUnsupported }
+
+// find returns the results of the query name.
+func (dir *searchDir) find(name string) (fileWrapper, error) {
+	query, err := dsk.ParseQuery(name)
+	if err != nil {
+		return nil, os.ErrNotExist // webdav.Handler only knows this error as 404
+	}
+
+	var generations []uint64
+	for _, d := range dir.dfs.disks {
+		generations = append(generations, d.Generation())
+	}
+	dir.mu.Lock()
+	results, ok := dir.results[name]
+	dir.mu.Unlock()
+	if ok && slices.Equal(results.generations, generations) {
+		return results.dir, nil
+	}
+
+	// Searching every disk takes a while, so other queries are not kept
+	// waiting on it. The same query asked twice at once is searched twice.
+	results = &searchResults{generations: generations, dir: dir.search(name, query)}
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+	if dir.results == nil {
+		dir.results = make(map[string]*searchResults)
+	}
+	if _, ok := dir.results[name]; !ok {
+		dir.order = append(dir.order, name)
+	}
+	dir.results[name] = results
+	if len(dir.order) > maxSearches {
+		delete(dir.results, dir.order[0])
+		dir.order = dir.order[1:]
+	}
+	return results.dir, nil
+}
+
+// search returns the folder of the results of query on every disk.
+func (dir *searchDir) search(name string, query dsk.Query) *memDir {
+	modTime := dir.dfs.created
+	results := &memDir{name: name, modTime: modTime, children: newDirEntries()}
+	var index strings.Builder
+	index.WriteString("DISK\tFILE\tWHERE\tOFFSET\n")
+
+	for _, child := range dir.dfs.root.names() {
+		wrapper, _ := dir.dfs.root.get(child)
+		disk, ok := wrapper.(*dskDir)
+		if !ok {
+			continue
+		}
+		hits, err := dsk.Search(disk.dsk, query)
+		if err != nil {
+			// Say why a disk with a damaged catalog was only partly searched.
+			errName := child + ".error.txt"
+			results.children.add(errName, newMemFile(errName, err.Error()+"\n", modTime))
+		}
+		if len(hits) == 0 {
+			continue
+		}
+		files := &memDir{name: child, modTime: disk.dsk.ModTime(), children: newDirEntries()}
+		for _, hit := range hits {
+			fmt.Fprintf(&index, "%s\t%s\t%s\t%d\n", child, hit.Name, hit.Where, hit.Offset)
+			if file, ok := disk.Children().get(hit.Name); ok {
+				files.children.add(hit.Name, file)
+			}
+		}
+		results.children.add(child, files)
+	}
+	results.children.add(snIndex(), newMemFile(snIndex(), index.String(), modTime))
+	return results
+}
@@ -2,4 +2,7 @@ module taeber.rapczak.com/webdavfs
 
 go 1.22.2
 
-require golang.org/x/net v0.25.0
+require (
+	golang.org/x/net v0.25.0
+	golang.org/x/text v0.15.0
+)